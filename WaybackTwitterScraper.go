@@ -1,40 +1,54 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	http "github.com/bogdanfinn/fhttp"
-	"github.com/corona10/goimghdr"
-	"github.com/gookit/color"
 )
 
 func main() {
-	log.SetOutput(os.Stdout)
-	DrawTitle()                    // Draw the title of the program
+	if err := ConfigureLogging(); err != nil {
+		slog.Error("Invalid logging options", "error", err)
+		os.Exit(1)
+	}
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if command, ok := Commands[args[0]]; ok {
+			command(args[1:])
+			return
+		}
+		if args[0] == "scrape" {
+			args = args[1:]
+		} else if command := similarCommand(args[0]); command != "" {
+			slog.Error(fmt.Sprintf("Unknown command: %s, did you mean %s? Use `waybackScraper scrape %s` to scrape a user of that name", args[0], command, args[0]))
+			printUsage()
+			os.Exit(1)
+		}
+	}
+	ParseScrapeFlags(args)
+
+	DrawTitle()                    // Draw the title of the program when logging for people
 	inputUsername(TwitterUsername) // Prompt user for Twitter username
-	CreateDirectories()            // Create necessary directories for storing images
-	LoadProxies()                  // Load proxies from the proxies.txt file
-	CreateStoredImageMap()         // Create an in-memory map of stored images
-	fetchWaybackPages()            // Fetch Wayback Machine cached pages
-	parseImages()                  // Parse images from the cached pages
-	RemoveCommonItems()            // Remove previously downloaded images from the unprocessedImages list
-	downloadImages()               // Download images from the Wayback Machine cache
-	purgeCorrupted()               // Purge any corrupted images
-	createReport()                 // Create a report of the downloaded images
+	StartMetricsServer()           // Serve Prometheus metrics if enabled
+	LoadProxies()                  // Load proxies from the proxy file
+	OpenLocalArchives()            // Index local WARC/WACZ files when working offline
+	Scrape()                       // Find, download and report on the user's archived images
 }
 
 func inputUsername(defaultUser string) {
 	if defaultUser != "" {
 		TwitterUsername = defaultUser
-		WaybackResultsURL = fmt.Sprintf("https://web.archive.org/web/timemap/json?url=twitter.com/%s&matchType=prefix", TwitterUsername)
+		WaybackResultsURL = timemapURL()
 		return
 	}
 
@@ -46,161 +60,238 @@ func inputUsername(defaultUser string) {
 		fmt.Scanln(&TwitterUsername)
 	}
 
-	WaybackResultsURL = fmt.Sprintf("https://web.archive.org/web/timemap/json?url=twitter.com/%s&matchType=prefix", TwitterUsername)
+	WaybackResultsURL = timemapURL()
+}
+
+// timemapURL lists the user's captures, starting from SinceTimestamp when only new captures are wanted
+func timemapURL() string {
+	resultsURL := fmt.Sprintf("%s/web/timemap/json?url=twitter.com/%s&matchType=prefix", WaybackHost, TwitterUsername)
+	if SinceTimestamp != "" {
+		resultsURL += "&from=" + SinceTimestamp
+	}
+	return resultsURL
 }
 
 func fetchWaybackPages() {
-	color.Cyan.Printf("Fetching list of Wayback Machine cached pages for profile: %s\n", TwitterUsername)
+	slog.Info("Fetching list of Wayback Machine cached pages", "stage", "timemap", "username", TwitterUsername)
 
 	var waybackResults [][]interface{}
 	var req *http.Request
 	var resp *http.Response
-	var err error
 
-	httpClient := GetProxyClient()
+	httpClient, err := GetProxyClient()
+	if err != nil {
+		slog.Error("Error fetching Wayback Machine results", "stage", "timemap", "url", WaybackResultsURL, "error", err)
+		TimemapFailed = true
+		return
+	}
 	defer returnProxy(httpClient)
 
-	req, err = http.NewRequest(http.MethodGet, WaybackResultsURL, http.NoBody)
+	req, err = newRequest(WaybackResultsURL)
 	if err != nil {
-		log.Println(err)
+		slog.Error("Error building Wayback Machine results request", "stage", "timemap", "url", WaybackResultsURL, "error", err)
+		TimemapFailed = true
 		return
 	}
 
-	for i := 0; i < 5; i++ {
+	fetched := false
+	for i := 0; i < 5 && !Cancelled(); i++ {
+		ObserveAttempt("timemap", i)
+		requestStarted := time.Now()
 		resp, err = httpClient.Do(req)
+		ObserveResponse("timemap", resp, err)
 		if err != nil {
-			color.Red.Printf("Retrying - Error fetching Wayback Machine results: %+v\n", err)
+			slog.Warn("Retrying - Error fetching Wayback Machine results", "stage", "timemap", "url", WaybackResultsURL, "attempt", i+1, "proxy", redactProxy(httpClient.GetProxy()), "duration", time.Since(requestStarted), "error", err)
 			rotateClientProxy(httpClient)
 			continue
 		}
 		defer resp.Body.Close()
 
 		if err := json.NewDecoder(resp.Body).Decode(&waybackResults); err != nil {
-			color.Red.Printf("Error decoding Wayback Machine results: %+v\n", err)
+			slog.Warn("Retrying - Error decoding Wayback Machine results", "stage", "timemap", "url", WaybackResultsURL, "attempt", i+1, "proxy", redactProxy(httpClient.GetProxy()), "status", resp.StatusCode, "error", err)
 			rotateClientProxy(httpClient)
 			continue
 		}
+		fetched = true
 		break
 	}
+	if !fetched && !Cancelled() {
+		slog.Error("Error fetching Wayback Machine results after 5 attempts - exiting", "stage", "timemap", "url", WaybackResultsURL, "username", TwitterUsername)
+		TimemapFailed = true
+		return
+	}
 
+	// Each result is a CDX row: urlkey, timestamp, original, ... - the first row is the header
+	// The queue is filled under its lock, as progress and metrics are read from other goroutines
+	PageMutex.Lock()
 	for _, result := range waybackResults {
-		pageURL := result[2].(string)
+		if len(result) < 3 {
+			continue
+		}
+		timestamp, _ := result[1].(string)
+		pageURL, _ := result[2].(string)
+		// from is inclusive, and local archives ignore it, so already processed captures are dropped here
+		if SinceTimestamp != "" && timestamp <= SinceTimestamp {
+			continue
+		}
 		if strings.Contains(pageURL, `http`) {
-			PageUnprocessed = append(PageUnprocessed, pageURL)
+			PageUnprocessed = append(PageUnprocessed, Snapshot{Timestamp: timestamp, URL: pageURL})
 		}
 	}
 
 	TotalPages = len(PageUnprocessed)
-
-	if TotalPages == 0 {
-		color.Red.Printf("Found %d cached Wayback Machine pages - exiting\n", TotalPages)
-		os.Exit(0)
+	PageSnapshots = append([]Snapshot{}, PageUnprocessed...)
+	PageMutex.Unlock()
+	CatalogSnapshots(PageSnapshots)
+
+	if TotalPages == 0 && SinceTimestamp != "" {
+		slog.Info("Found no new cached Wayback Machine pages", "stage", "timemap", "username", TwitterUsername, "since", SinceTimestamp)
+	} else if TotalPages == 0 {
+		slog.Error("Found no cached Wayback Machine pages - exiting", "stage", "timemap", "username", TwitterUsername)
 	} else {
-
-		color.Cyan.Printf("Found %d cached Wayback Machine pages\n", TotalPages)
+		slog.Info("Found cached Wayback Machine pages", "stage", "timemap", "pages", TotalPages)
 	}
 }
 
 func parseImages() {
-	color.LightGreen.Printf("\n=== Visiting the cached pages and checking for images\n")
+	slog.Info("=== Visiting the cached pages and checking for images", "stage", "pages")
 
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, MaxThreads) // Limit to "MaxThreads" concurrent goroutines
 
-	for len(PageUnprocessed) > 0 {
-		wg.Add(1)
-		var pageURL string
+	// The queue is only read under its lock, as workers put failed pages back while the loop runs
+	for !Cancelled() {
+		var snapshot Snapshot
 
 		PageMutex.Lock()
-		PageUnprocessed, pageURL = Pop(PageUnprocessed)
+		if len(PageUnprocessed) == 0 {
+			PageMutex.Unlock()
+			break
+		}
+		PageUnprocessed, snapshot = Pop(PageUnprocessed)
 		PageMutex.Unlock()
+		wg.Add(1)
 
-		go func(pageURL string) { // Pass pageURL as an argument
+		go func(snapshot Snapshot) { // Pass snapshot as an argument
 			defer wg.Done()
 
 			sem <- struct{}{}        // Acquire semaphore
 			defer func() { <-sem }() // Release semaphore
+			defer TrackWorker("pages")()
 
-			combinedURL := WaybackPrefix + pageURL
-			color.Gray.Printf("%s - Visiting %s to parse images\n", GetPageProgress(), pageURL)
+			pageURL := snapshot.URL
+			combinedURL := snapshot.WaybackURL()
+			slog.Debug("Visiting page to parse images", "stage", "pages", "url", pageURL, "progress", GetPageProgress())
 
 			htmlContent, err := parseImagesWithRetry(combinedURL)
 			switch err {
 			case nil:
 				PageMutex.Lock()
-				PageProcessed = append(PageProcessed, pageURL)
+				PageProcessed = append(PageProcessed, snapshot)
 				PageMutex.Unlock()
-				color.Green.Printf("%s - Successfully parsed %s\n", GetPageProgress(), pageURL)
+				RecordPage(snapshot, "parsed", nil)
+				slog.Info("Parsed page", "stage", "pages", "url", pageURL, "progress", GetPageProgress())
 			case ErrPageMissingContent:
-				color.FgDarkGray.Printf("Skipping %s - not a valid page\n", pageURL)
+				RecordPage(snapshot, "skipped", err)
+				slog.Info("Skipping page - not a valid page", "stage", "pages", "url", pageURL)
+				return
+			case ErrHTTPClient:
+				// Requeuing would only fail again, so the page is left for the next run
+				slog.Error("Error parsing images from page", "stage", "pages", "url", combinedURL, "error", err)
+				RecordFailure("pages", combinedURL, err)
 				return
 			case ErrPageRetries:
 				fallthrough
 			default:
-				color.Red.Printf("Error parsing images from %s - %s\n", combinedURL, err)
+				slog.Error("Error parsing images from page", "stage", "pages", "url", combinedURL, "error", err)
+				RecordFailure("pages", combinedURL, err)
 				PageMutex.Lock()
-				PageUnprocessed = append(PageUnprocessed, pageURL)
+				PageUnprocessed = append(PageUnprocessed, snapshot)
 				PageMutex.Unlock()
 				return
 			}
 
-			for _, resource := range Resources {
-				var resourceURLs []string
-				switch resource {
-				case "media":
-					resourceURLs = MediaRegex.FindAllString(htmlContent, -1)
-				case "profile":
-					resourceURLs = ProfileRegex.FindAllString(htmlContent, -1)
-				}
-				ImageMutex.Lock()
-				ImageUnprocessed = RemoveDuplicates(append(ImageUnprocessed, resourceURLs...))
-				ImageMutex.Unlock()
-			}
-		}(pageURL)
+			CachePage(snapshot, htmlContent)
+			extractImages(htmlContent, snapshot)
+		}(snapshot)
 	}
 	wg.Wait()
 
+	ImageMutex.Lock()
 	TotalImages = len(ImageUnprocessed)
-	color.Green.Printf("\nFound %d cached images for: %s\n", TotalImages, TwitterUsername)
+	DiscoveredImages = append([]string{}, ImageUnprocessed...)
+	ImageMutex.Unlock()
+	sort.Strings(DiscoveredImages)
+	slog.Info("Found cached images", "stage", "pages", "images", TotalImages, "username", TwitterUsername)
+}
+
+// extractImages queues every resource image found in a page's HTML for download
+func extractImages(htmlContent string, snapshot Snapshot) {
+	for _, resource := range Resources {
+		var resourceURLs []string
+		switch resource {
+		case "media":
+			resourceURLs = MediaRegex.FindAllString(htmlContent, -1)
+		case "profile":
+			resourceURLs = ProfileRegex.FindAllString(htmlContent, -1)
+		case "banner":
+			resourceURLs = BannerRegex.FindAllString(htmlContent, -1)
+		}
+		for _, resourceURL := range resourceURLs {
+			RecordSighting(resourceURL, snapshot)
+		}
+		ImageMutex.Lock()
+		ImageUnprocessed = RemoveDuplicates(append(ImageUnprocessed, resourceURLs...))
+		ImageMutex.Unlock()
+	}
 }
 
 func parseImagesWithRetry(combinedURL string) (string, error) {
 	var req *http.Request
 	var resp *http.Response
-	var err error
 
-	httpClient := GetProxyClient()
+	httpClient, err := GetProxyClient()
+	if err != nil {
+		return "", err
+	}
 	defer returnProxy(httpClient)
 
-	for i := 0; i < RetryAttempts; i++ {
-		req, err = http.NewRequest(http.MethodGet, combinedURL, http.NoBody)
+	for i := 0; i < RetryAttempts && !Cancelled(); i++ {
+		ObserveAttempt("pages", i)
+		req, err = newRequest(combinedURL)
 		if err != nil {
-			color.Red.Printf("Error building parse request: %+v\n", err)
+			slog.Warn("Retrying - Error building page request", "stage", "pages", "url", combinedURL, "attempt", i+1, "error", err)
 			rotateClientProxy(httpClient)
 			continue
 		}
 
+		requestStarted := time.Now()
 		resp, err = httpClient.Do(req)
+		RecordLatency("pages", time.Since(requestStarted))
+		ObserveResponse("pages", resp, err)
 		if err != nil {
-			color.Red.Printf("Error fetching page content: %+v\n", err)
+			slog.Warn("Retrying - Error fetching page content", "stage", "pages", "url", combinedURL, "attempt", i+1, "proxy", redactProxy(httpClient.GetProxy()), "duration", time.Since(requestStarted), "error", err)
 			rotateClientProxy(httpClient)
 			continue
 		}
 		defer resp.Body.Close()
 
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			slog.Warn("Retrying - Error reading page content", "stage", "pages", "url", combinedURL, "attempt", i+1, "proxy", redactProxy(httpClient.GetProxy()), "status", resp.StatusCode, "error", err)
+			rotateClientProxy(httpClient)
+			continue
+		}
+
+		// Every answer is archived, so the WARC shows what was not archived as well as what was
+		ArchiveExchange(req, resp, bytes.NewReader(body), int64(len(body)))
+
 		if resp.StatusCode == 404 {
 			return "404 - Not a valid page", ErrPageMissingContent
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			color.Red.Printf("Error: HTTP request failed with status code %d\n", resp.StatusCode)
-			rotateClientProxy(httpClient)
-			continue
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			color.Red.Printf("Error reading response body content for %s: %s\n", combinedURL, err)
+			slog.Warn("Retrying - Page request failed", "stage", "pages", "url", combinedURL, "attempt", i+1, "proxy", redactProxy(httpClient.GetProxy()), "status", resp.StatusCode, "duration", time.Since(requestStarted))
 			rotateClientProxy(httpClient)
 			continue
 		}
@@ -211,114 +302,144 @@ func parseImagesWithRetry(combinedURL string) (string, error) {
 	return "", ErrPageRetries
 }
 
-func downloadImageWithRetry(imageURL string, downloadPath string) error {
+// downloadImageWithRetry fetches the Wayback copy at imageURL, sourceURL is the original Twitter URL it archived
+func downloadImageWithRetry(sourceURL string, imageURL string, downloadPath string) error {
 	var req *http.Request
 	var resp *http.Response
-	var err error
 
-	httpClient := GetProxyClient()
+	httpClient, err := GetProxyClient()
+	if err != nil {
+		return err
+	}
 	defer returnProxy(httpClient)
 
-	for i := 0; i < RetryAttempts; i++ {
-		req, err = http.NewRequest(http.MethodGet, imageURL, http.NoBody)
+	for i := 0; i < RetryAttempts && !Cancelled(); i++ {
+		ObserveAttempt("images", i)
+		req, err = newRequest(imageURL)
 		if err != nil {
-			color.Red.Printf("Retrying - Error building image download request: %s\n", err)
+			slog.Warn("Retrying - Error building image request", "stage", "images", "url", imageURL, "attempt", i+1, "error", err)
 			continue
 		}
+		requestStarted := time.Now()
 		resp, err = httpClient.Do(req)
+		RecordLatency("images", time.Since(requestStarted))
+		ObserveResponse("images", resp, err)
 		if err != nil {
-			color.Red.Printf("Retrying - Error fetching image: %+v\n", err)
+			slog.Warn("Retrying - Error fetching image", "stage", "images", "url", imageURL, "attempt", i+1, "proxy", redactProxy(httpClient.GetProxy()), "duration", time.Since(requestStarted), "error", err)
 			rotateClientProxy(httpClient)
 			continue
 		}
 
 		defer resp.Body.Close()
 
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			color.Red.Printf("Retrying - Error reading image: %s\n", err)
-			rotateClientProxy(httpClient)
-			continue
+		if resp.StatusCode != http.StatusOK {
+			ArchiveResponse(req, resp, resp.Body)
 		}
 
-		if resp.StatusCode == 404 && resp.Header.Get("Content-Type") == "text/html" {
+		if resp.StatusCode == 404 && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
 			return ErrPageMissingContent
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			color.Red.Printf("Retrying - Error fetching image: HTTP status %d\n", resp.StatusCode)
+			slog.Warn("Retrying - Image request failed", "stage", "images", "url", imageURL, "attempt", i+1, "proxy", redactProxy(httpClient.GetProxy()), "status", resp.StatusCode, "duration", time.Since(requestStarted))
 			rotateClientProxy(httpClient)
 			continue
 		}
 
-		file, err := os.Create(downloadPath)
-		if err != nil {
-			color.Red.Printf("Retrying - Error creating file: %s\n", err)
+		// Peek at the start of the body to classify it without buffering the whole payload
+		bodyReader := bufio.NewReaderSize(resp.Body, 512)
+		sniff, err := bodyReader.Peek(512)
+		if err != nil && err != io.EOF {
+			slog.Warn("Retrying - Error reading image", "stage", "images", "url", imageURL, "attempt", i+1, "proxy", redactProxy(httpClient.GetProxy()), "status", resp.StatusCode, "error", err)
 			rotateClientProxy(httpClient)
 			continue
 		}
-		defer file.Close()
 
-		_, err = io.Copy(file, bytes.NewReader(bodyBytes))
-		if err != nil {
-			color.Red.Printf("Retrying - Error saving image: %s\n", err)
+		if err := ClassifyPayload(resp, sourceURL, sniff); err != nil {
+			ArchiveResponse(req, resp, bodyReader)
+			return err
+		}
+
+		if _, err := WriteFileAtomic(bodyReader, downloadPath, resp.ContentLength); err != nil {
+			slog.Warn("Retrying - Error saving image", "stage", "images", "url", imageURL, "attempt", i+1, "path", downloadPath, "error", err)
 			rotateClientProxy(httpClient)
 			continue
 		}
+
+		ArchiveDownloadedFile(req, resp, downloadPath)
+		ApplyArchiveMetadata(downloadPath, sourceURL, resp)
 		return nil
 	}
-	color.Red.Printf("Aborting - Error downloading image after %d retries: %s\n", RetryAttempts, imageURL)
+	slog.Error("Aborting - Error downloading image after every retry", "stage", "images", "url", imageURL, "attempts", RetryAttempts)
 	return ErrImageRetries
 }
 
 func downloadImages() {
-	color.LightGreen.Println("Downloading identified images from Wayback Machine Cache\n")
+	slog.Info("=== Downloading identified images from Wayback Machine Cache", "stage", "images")
 
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, MaxThreads) // Limit to "MaxThreads" concurrent goroutines
 
-	for len(ImageUnprocessed) > 0 {
-		wg.Add(1)
+	// The queue is only read under its lock, as workers put failed images back while the loop runs
+	for !Cancelled() {
 		var imageURL string
-		var imageType string
 
 		ImageMutex.Lock()
+		if len(ImageUnprocessed) == 0 {
+			ImageMutex.Unlock()
+			break
+		}
 		ImageUnprocessed, imageURL = Pop(ImageUnprocessed)
 		ImageMutex.Unlock()
+		wg.Add(1)
 
-		if strings.Contains(imageURL, "media") {
-			imageType = "media"
-		} else {
-			imageType = "profile"
-		}
+		imageType := ImageResource(imageURL)
 
 		go func(imageURL string, imageType string) {
 			defer wg.Done()
 
 			sem <- struct{}{}        // Acquire semaphore
 			defer func() { <-sem }() // Release semaphore
+			defer TrackWorker("images")()
 
-			imageName := FilenameRegex.FindString(imageURL)
-			combinedURL := WaybackPrefix + imageURL
+			imageName := ImageFilename(imageURL)
+			combinedURL := WaybackHost + WaybackPrefix + imageURL
 			downloadPath := fmt.Sprintf("%s/%s/%s", UsernameLocation, imageType, imageName)
 
-			err := downloadImageWithRetry(combinedURL, downloadPath)
+			err := downloadImageWithRetry(imageURL, combinedURL, downloadPath)
 			switch err {
 			case nil:
 				ImageMutex.Lock()
 				TotalDownloads += 1
 				ImageProcessed = append(ImageProcessed, imageURL)
 				ImageMutex.Unlock()
-				color.Green.Printf("%s - Saved %s\n", GetImageProgress(), imageURL)
+				record := ImageRecord{URL: imageURL, Resource: imageType, Status: "downloaded", Path: downloadPath}
+				record.Size, record.SHA256, _ = HashFile(downloadPath)
+				RecordImage(record)
+				slog.Info("Saved image", "stage", "images", "url", imageURL, "path", downloadPath, "bytes", record.Size, "progress", GetImageProgress())
 				return
 			case ErrPageMissingContent:
 				ImageMutex.Lock()
 				ImageProcessed = append(ImageProcessed, imageURL)
 				ImageMutex.Unlock()
-				color.FgDarkGray.Printf("Skipping %s - not a valid image file\n", imageURL)
+				RecordImage(ImageRecord{URL: imageURL, Resource: imageType, Status: "skipped", Reason: err.Error()})
+				slog.Info("Skipping image - not a valid image file", "stage", "images", "url", imageURL)
+				return
+			case ErrPayloadEmpty, ErrPayloadPlaceholder, ErrPayloadRedirected, ErrPayloadNotImage:
+				ImageMutex.Lock()
+				ImageProcessed = append(ImageProcessed, imageURL)
+				ImageMutex.Unlock()
+				RecordImage(ImageRecord{URL: imageURL, Resource: imageType, Status: "skipped", Reason: err.Error()})
+				slog.Info("Skipping image", "stage", "images", "url", imageURL, "reason", err)
+				return
+			case ErrHTTPClient:
+				// Requeuing would only fail again, so the image is left for the next run
+				slog.Error("Error downloading image", "stage", "images", "url", combinedURL, "error", err)
+				RecordFailure("images", combinedURL, err)
 				return
 			default:
-				color.Red.Printf("Error downloading image from %s - %s\n", combinedURL, err.Error())
+				slog.Error("Error downloading image", "stage", "images", "url", combinedURL, "error", err)
+				RecordFailure("images", combinedURL, err)
 				ImageMutex.Lock()
 				ImageUnprocessed = append(ImageUnprocessed, imageURL)
 				ImageMutex.Unlock()
@@ -328,70 +449,5 @@ func downloadImages() {
 	}
 	wg.Wait()
 
-	color.Green.Printf("\nSaved %d images for: %s\n", TotalDownloads, TwitterUsername)
-}
-func purgeCorrupted() {
-	color.Gray.Printf("Purging any corrupted images in %s\n", UsernameLocation)
-
-	images, err := filepath.Glob(fmt.Sprintf("%s/images/%s/*.jpg", HomeDirectory, TwitterUsername))
-	if err != nil {
-		color.Red.Printf("Error listing image files: %+v\n", err)
-		return
-	}
-
-	purgedCounter := 0
-
-	for _, image := range images {
-		if _, err := os.Stat(image); err != nil {
-			color.Red.Printf("Error checking image file: %s\n", err)
-			continue
-		}
-		if _, err := os.Stat(image); err == nil {
-			filetype, _ := goimghdr.What(image)
-			if filetype != "jpeg" {
-				if err := os.Remove(image); err != nil {
-					color.Red.Printf("Error removing corrupted image: %s\n", err)
-					continue
-				}
-				color.Yellow.Printf("Removed corrupted image: %s\n", image)
-				purgedCounter += 1
-			}
-		}
-	}
-
-	if purgedCounter == 0 {
-		color.Green.Printf("No corrupted images found - success!\n")
-	} else {
-		color.Green.Printf("Purged %d corrupted images\n", purgedCounter)
-	}
-}
-
-func createReport() {
-	header := fmt.Sprintf(`=== Wayback Report - %s - %s`, TwitterUsername, GetCurrentDate())
-	totalProcessed := fmt.Sprintf("Pages Parsed: %d | Images Proccesed: %d | Downloaded Images: %d", TotalPages, TotalImages, TotalDownloads)
-	pageString := ""
-	for _, link := range PageProcessed {
-		pageString += fmt.Sprintf("%s\n", link)
-	}
-	imageString := ""
-	for _, link := range ImageProcessed {
-		imageString += fmt.Sprintf("%s\n", link)
-	}
-
-	report := fmt.Sprintf("%s\n%s\n%s\n%s\n", header, totalProcessed, pageString, imageString)
-
-	reportFile, err := os.Create(fmt.Sprintf("%s/%s-report.txt", UsernameLocation, GetCurrentDate()))
-	if err != nil {
-		color.Red.Printf("Error creating report file: %+v\n", err)
-		return
-	}
-	defer reportFile.Close()
-
-	_, err = reportFile.WriteString(report)
-	if err != nil {
-		color.Red.Printf("Error writing to report file: %+v\n", err)
-		return
-	}
-
-	color.Magenta.Printf("Report created: %s/%s-report.txt\n", UsernameLocation, GetCurrentDate())
+	slog.Info("Saved images", "stage", "images", "images", TotalDownloads, "username", TwitterUsername)
 }
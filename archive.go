@@ -0,0 +1,404 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// Matches a Wayback URL, capturing the timestamp and the original URL
+var waybackURLRegex = regexp.MustCompile(`^https?://[^/]+/web/([0-9]{14})[a-z_]*/(.+)$`)
+
+// ArchiveRecord locates a single WARC response record inside a local WARC or WACZ file
+type ArchiveRecord struct {
+	Source    string // File the record is stored in
+	Base      int64  // Start of the WARC data within Source, non-zero for WARCs stored inside a WACZ
+	Offset    int64  // Start of the record relative to Base
+	Gzip      bool
+	Original  string // Original URL, i.e. https://twitter.com/jack
+	Timestamp string // Capture timestamp, i.e. 20200126021126
+	MimeType  string
+	Status    int
+}
+
+// Archive is an index of the response records in a set of local WARC and WACZ files
+type Archive struct {
+	Records map[string][]ArchiveRecord // Keyed by archiveKey of the original URL
+}
+
+// archiveKey normalises a URL so captures of http/https and www/non-www variants match
+func archiveKey(rawURL string) string {
+	key := strings.ToLower(rawURL)
+	key = strings.TrimPrefix(key, "https://")
+	key = strings.TrimPrefix(key, "http://")
+	key = strings.TrimPrefix(key, "www.")
+	return strings.TrimSuffix(key, "/")
+}
+
+// splitWaybackURL returns the capture timestamp and original URL of a Wayback URL
+func splitWaybackURL(rawURL string) (string, string, bool) {
+	match := waybackURLRegex.FindStringSubmatch(rawURL)
+	if match == nil {
+		return "", rawURL, false
+	}
+	return match[1], match[2], true
+}
+
+// countingReader tracks how many bytes have been read from the underlying file
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// readRecordHeader reads the WARC version line and named fields of a record
+func readRecordHeader(reader *bufio.Reader) (textproto.MIMEHeader, error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, "WARC/") {
+			break
+		}
+		if strings.TrimSpace(line) != "" {
+			return nil, fmt.Errorf("expected WARC version line, got %q", line)
+		}
+	}
+	return textproto.NewReader(reader).ReadMIMEHeader()
+}
+
+// indexRecords reads every record from a WARC stream starting at base within source
+func (a *Archive) indexRecords(source string, base int64, section io.ReaderAt, size int64) error {
+	counter := &countingReader{reader: io.NewSectionReader(section, 0, size)}
+	buffered := bufio.NewReader(counter)
+
+	magic, _ := buffered.Peek(2)
+	isGzip := len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+
+	for {
+		offset := counter.count - int64(buffered.Buffered())
+		if _, err := buffered.Peek(1); err == io.EOF {
+			return nil
+		}
+
+		var recordReader *bufio.Reader
+		var member *gzip.Reader
+		if isGzip {
+			var err error
+			member, err = gzip.NewReader(buffered)
+			if err != nil {
+				return err
+			}
+			member.Multistream(false)
+			recordReader = bufio.NewReader(member)
+		} else {
+			recordReader = buffered
+		}
+
+		header, err := readRecordHeader(recordReader)
+		if err != nil {
+			return err
+		}
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid Content-Length at offset %d", offset)
+		}
+
+		block := io.LimitReader(recordReader, length)
+		if header.Get("WARC-Type") == "response" {
+			a.addRecord(source, base, offset, isGzip, header, block)
+		}
+		if _, err := io.Copy(io.Discard, block); err != nil {
+			return err
+		}
+
+		if isGzip {
+			// Drain the rest of the member so the next gzip header starts the following record
+			io.Copy(io.Discard, recordReader)
+			member.Close()
+		} else {
+			// Skip the two CRLFs that end each record
+			for {
+				next, err := buffered.Peek(1)
+				if err != nil || (next[0] != '\r' && next[0] != '\n') {
+					break
+				}
+				buffered.Discard(1)
+			}
+		}
+	}
+}
+
+func (a *Archive) addRecord(source string, base int64, offset int64, isGzip bool, header textproto.MIMEHeader, block io.Reader) {
+	targetURI := strings.Trim(header.Get("WARC-Target-URI"), "<>")
+	timestamp, original, isWayback := splitWaybackURL(targetURI)
+	if !isWayback {
+		captured, err := time.Parse(time.RFC3339, header.Get("WARC-Date"))
+		if err != nil {
+			return
+		}
+		timestamp = captured.UTC().Format("20060102150405")
+	}
+
+	// Only the HTTP status line and headers are needed for the index
+	httpHead := textproto.NewReader(bufio.NewReader(block))
+	statusLine, err := httpHead.ReadLine()
+	if err != nil {
+		return
+	}
+	statusParts := strings.SplitN(statusLine, " ", 3)
+	if len(statusParts) < 2 {
+		return
+	}
+	status, _ := strconv.Atoi(statusParts[1])
+	httpHeader, _ := httpHead.ReadMIMEHeader()
+
+	key := archiveKey(original)
+	a.Records[key] = append(a.Records[key], ArchiveRecord{
+		Source:    source,
+		Base:      base,
+		Offset:    offset,
+		Gzip:      isGzip,
+		Original:  original,
+		Timestamp: timestamp,
+		MimeType:  strings.TrimSpace(strings.Split(httpHeader.Get("Content-Type"), ";")[0]),
+		Status:    status,
+	})
+}
+
+// indexFile indexes a single .warc, .warc.gz or .wacz file
+func (a *Archive) indexFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(strings.ToLower(path), ".wacz") {
+		return a.indexRecords(path, 0, file, info.Size())
+	}
+
+	waczFile, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, entry := range waczFile.File {
+		if !strings.HasPrefix(entry.Name, "archive/") || !strings.Contains(entry.Name, ".warc") {
+			continue
+		}
+		// The WACZ spec stores WARCs uncompressed within the zip so they can be read in place
+		if entry.Method != zip.Store {
+			return fmt.Errorf("%s in %s is compressed within the zip, WACZ requires stored WARC files", entry.Name, path)
+		}
+		dataOffset, err := entry.DataOffset()
+		if err != nil {
+			return err
+		}
+		section := io.NewSectionReader(file, dataOffset, int64(entry.UncompressedSize64))
+		if err := a.indexRecords(path, dataOffset, section, int64(entry.UncompressedSize64)); err != nil {
+			return fmt.Errorf("%s: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// OpenArchive indexes the given WARC and WACZ files
+func OpenArchive(paths []string) (*Archive, error) {
+	archive := &Archive{Records: make(map[string][]ArchiveRecord)}
+	for _, path := range paths {
+		if err := archive.indexFile(path); err != nil {
+			return nil, fmt.Errorf("indexing %s: %w", path, err)
+		}
+	}
+	return archive, nil
+}
+
+// Closest returns the successful capture of rawURL nearest to timestamp
+func (a *Archive) Closest(rawURL string, timestamp string) (ArchiveRecord, bool) {
+	var best ArchiveRecord
+	found := false
+	target := ParseWaybackTimestamp(timestamp)
+
+	for _, record := range a.Records[archiveKey(rawURL)] {
+		if record.Status != http.StatusOK {
+			continue
+		}
+		distance := ParseWaybackTimestamp(record.Timestamp).Sub(target).Abs()
+		if !found || distance < ParseWaybackTimestamp(best.Timestamp).Sub(target).Abs() {
+			best = record
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Open reads the stored HTTP response of a record, the body must be closed by the caller
+func (a *Archive) Open(record ArchiveRecord, req *http.Request) (*http.Response, error) {
+	file, err := os.Open(record.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader *bufio.Reader
+	section := io.NewSectionReader(file, record.Base+record.Offset, 1<<62)
+	if record.Gzip {
+		member, err := gzip.NewReader(section)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		member.Multistream(false)
+		reader = bufio.NewReader(member)
+	} else {
+		reader = bufio.NewReader(section)
+	}
+
+	if _, err := readRecordHeader(reader); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{resp.Body, file}
+	return resp, nil
+}
+
+// Timemap answers a timemap query with CDX rows built from the index, in the same shape as the Wayback API
+func (a *Archive) Timemap(prefix string) [][]string {
+	rows := [][]string{{"urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"}}
+
+	prefix = archiveKey(prefix)
+	var matches []ArchiveRecord
+	for key, records := range a.Records {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		for _, record := range records {
+			if record.Status == http.StatusOK && record.MimeType == "text/html" {
+				matches = append(matches, record)
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Timestamp < matches[j].Timestamp })
+
+	for _, record := range matches {
+		rows = append(rows, []string{archiveKey(record.Original), record.Timestamp, record.Original, record.MimeType, "200", "-", "-"})
+	}
+	return rows
+}
+
+// ArchiveClient serves requests from a local Archive instead of the network
+type ArchiveClient struct {
+	archive *Archive
+}
+
+func newArchiveResponse(req *http.Request, status int, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func (c *ArchiveClient) Do(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/timemap/json") {
+		body, err := json.Marshal(c.archive.Timemap(req.URL.Query().Get("url")))
+		if err != nil {
+			return nil, err
+		}
+		return newArchiveResponse(req, http.StatusOK, "application/json", body), nil
+	}
+
+	timestamp, original, _ := splitWaybackURL(req.URL.String())
+	record, ok := c.archive.Closest(original, timestamp)
+	if !ok {
+		// Misses look like Wayback's own 404 so they are skipped rather than retried
+		return newArchiveResponse(req, http.StatusNotFound, "text/html", []byte("<html>Not in local archive</html>")), nil
+	}
+
+	// Point the response at the capture actually served, as a Wayback redirect would
+	servedURL, err := url.Parse(fmt.Sprintf("%s/web/%sid_/%s", WaybackHost, record.Timestamp, record.Original))
+	if err != nil {
+		return nil, err
+	}
+	served := req.Clone(req.Context())
+	served.URL = servedURL
+
+	return c.archive.Open(record, served)
+}
+
+func (c *ArchiveClient) SetProxy(proxyURL string) error { return nil }
+func (c *ArchiveClient) GetProxy() string               { return "" }
+
+// OpenLocalArchives indexes ArchivePaths, switching every fetch to the local archive
+func OpenLocalArchives() {
+	if len(ArchivePaths) == 0 {
+		return
+	}
+
+	var paths []string
+	for _, path := range ArchivePaths {
+		// Directories are expanded to the WARC and WACZ files inside them
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			for _, pattern := range []string{"*.warc", "*.warc.gz", "*.wacz"} {
+				matches, _ := filepath.Glob(filepath.Join(path, pattern))
+				paths = append(paths, matches...)
+			}
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	slog.Info("Indexing local archive files", "files", len(paths))
+	archive, err := OpenArchive(paths)
+	if err != nil {
+		Fatal("Error opening local archive", "error", err)
+	}
+
+	total := 0
+	for _, records := range archive.Records {
+		total += len(records)
+	}
+	slog.Info("Indexed response records - working offline", "records", total)
+	LocalArchive = archive
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const bagitDeclaration = "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"
+
+// bagPathReplacer percent-encodes the characters the BagIt spec forbids in manifest paths
+var bagPathReplacer = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// copyFile copies a payload file into the bag, keeping its mtime as it records the capture time
+func copyFile(source string, destination string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	input, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+		return err
+	}
+	if _, err := WriteFileAtomic(input, destination, info.Size()); err != nil {
+		return err
+	}
+	return os.Chtimes(destination, info.ModTime(), info.ModTime())
+}
+
+// writeManifest hashes each file relative to bagDir and writes a sha256 manifest
+func writeManifest(bagDir string, manifestName string, paths []string) (int64, error) {
+	var total int64
+	var manifest strings.Builder
+
+	sort.Strings(paths)
+	for _, relative := range paths {
+		size, hash, err := HashFile(filepath.Join(bagDir, relative))
+		if err != nil {
+			return 0, err
+		}
+		total += size
+		fmt.Fprintf(&manifest, "%s  %s\n", hash, bagPathReplacer.Replace(filepath.ToSlash(relative)))
+	}
+
+	return total, os.WriteFile(filepath.Join(bagDir, manifestName), []byte(manifest.String()), 0644)
+}
+
+// bagInfo builds bag-info.txt, including a summary of the latest scrape when a JSON report exists
+func bagInfo(payloadBytes int64, payloadFiles int) string {
+	var info strings.Builder
+	fmt.Fprintf(&info, "Bag-Software-Agent: wayback-twitter-scraper\n")
+	fmt.Fprintf(&info, "Bagging-Date: %s\n", time.Now().Format("2006-01-02"))
+	fmt.Fprintf(&info, "External-Description: Wayback Machine captures of Twitter account %s\n", TwitterUsername)
+	fmt.Fprintf(&info, "External-Identifier: twitter.com/%s\n", TwitterUsername)
+	fmt.Fprintf(&info, "Payload-Oxum: %d.%d\n", payloadBytes, payloadFiles)
+	fmt.Fprintf(&info, "Twitter-Username: %s\n", TwitterUsername)
+
+	if paths := RunReportPaths(); len(paths) > 0 {
+		if report, err := LoadRunReport(paths[len(paths)-1]); err == nil {
+			fmt.Fprintf(&info, "Scrape-Start-Time: %s\n", report.StartTime.Format(time.RFC3339))
+			fmt.Fprintf(&info, "Scrape-End-Time: %s\n", report.EndTime.Format(time.RFC3339))
+			fmt.Fprintf(&info, "Scrape-Pages-Found: %d\n", report.Counts["pages_found"])
+			fmt.Fprintf(&info, "Scrape-Images-Downloaded: %d\n", report.Counts["images_downloaded"])
+		}
+	}
+	return info.String()
+}
+
+// CreateBag copies the user directory into a new BagIt bag under outputDir and returns its path
+func CreateBag(outputDir string) (string, error) {
+	bagDir := filepath.Join(outputDir, fmt.Sprintf("%s-bag-%s", TwitterUsername, time.Now().Format("2006-01-02-150405")))
+	if _, err := os.Stat(bagDir); err == nil {
+		return "", fmt.Errorf("%s already exists", bagDir)
+	}
+
+	var payload []string
+	err := filepath.Walk(UsernameLocation, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, PartialSuffix) {
+			return nil
+		}
+
+		relative, err := filepath.Rel(UsernameLocation, path)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join("data", relative)
+		if err := copyFile(path, filepath.Join(bagDir, destination)); err != nil {
+			return err
+		}
+		payload = append(payload, destination)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payloadBytes, err := writeManifest(bagDir, "manifest-sha256.txt", payload)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(bagDir, "bagit.txt"), []byte(bagitDeclaration), 0644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(bagDir, "bag-info.txt"), []byte(bagInfo(payloadBytes, len(payload))), 0644); err != nil {
+		return "", err
+	}
+
+	tagFiles := []string{"bagit.txt", "bag-info.txt", "manifest-sha256.txt"}
+	if _, err := writeManifest(bagDir, "tagmanifest-sha256.txt", tagFiles); err != nil {
+		return "", err
+	}
+
+	return bagDir, nil
+}
+
+// readManifest parses a sha256 manifest into path -> hash
+func readManifest(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, relative, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			continue
+		}
+		relative = strings.TrimLeft(relative, " *")
+		relative = strings.NewReplacer("%0A", "\n", "%0D", "\r", "%25", "%").Replace(relative)
+		entries[relative] = strings.ToLower(hash)
+	}
+	return entries, scanner.Err()
+}
+
+// VerifyBag checks a bag's declaration, manifests and payload, returning every problem found
+func VerifyBag(bagDir string) []string {
+	var problems []string
+
+	if _, err := os.Stat(filepath.Join(bagDir, "bagit.txt")); err != nil {
+		return []string{"missing bagit.txt"}
+	}
+
+	checkManifest := func(manifestName string) map[string]string {
+		entries, err := readManifest(filepath.Join(bagDir, manifestName))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("unreadable %s: %s", manifestName, err))
+			return nil
+		}
+		for relative, expected := range entries {
+			_, actual, err := HashFile(filepath.Join(bagDir, filepath.FromSlash(relative)))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("missing file %s", relative))
+				continue
+			}
+			if actual != expected {
+				problems = append(problems, fmt.Sprintf("checksum mismatch for %s", relative))
+			}
+		}
+		return entries
+	}
+
+	if _, err := os.Stat(filepath.Join(bagDir, "tagmanifest-sha256.txt")); err == nil {
+		checkManifest("tagmanifest-sha256.txt")
+	}
+	payload := checkManifest("manifest-sha256.txt")
+
+	// Every file under data/ must be listed, an extra file means the payload was altered
+	filepath.Walk(filepath.Join(bagDir, "data"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		relative, _ := filepath.Rel(bagDir, path)
+		if _, ok := payload[filepath.ToSlash(relative)]; !ok && payload != nil {
+			problems = append(problems, fmt.Sprintf("file not in manifest: %s", filepath.ToSlash(relative)))
+		}
+		return nil
+	})
+
+	sort.Strings(problems)
+	return problems
+}
+
+func createBag(outputDir string) {
+	slog.Info("Packaging user directory as a BagIt bag", "path", UsernameLocation)
+
+	bagDir, err := CreateBag(outputDir)
+	if err != nil {
+		slog.Error("Error creating bag", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Bag created", "path", bagDir)
+}
+
+func verifyBag(bagDir string) {
+	problems := VerifyBag(bagDir)
+	if len(problems) == 0 {
+		slog.Info("Bag is valid", "path", bagDir)
+		return
+	}
+
+	for _, problem := range problems {
+		slog.Error("Bag problem", "path", bagDir, "problem", problem)
+	}
+	slog.Error("Bag is invalid", "path", bagDir, "problems", len(problems))
+	os.Exit(1)
+}
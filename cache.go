@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cachePath returns where a snapshot's HTML is cached, keyed by capture timestamp and URL
+func cachePath(snapshot Snapshot) string {
+	digest := sha1.Sum([]byte(snapshot.URL))
+	return filepath.Join(CacheDir, fmt.Sprintf("%s-%s.html.gz", snapshot.Timestamp, hex.EncodeToString(digest[:8])))
+}
+
+// ID of the gzip extra subfield holding a cached page's URL
+// The Comment field would be simpler, but gzip only allows Latin-1 there and URLs may hold any character
+var cacheURLField = [2]byte{'W', 'U'}
+
+// CachePage stores a fetched page's HTML gzipped on disk so extraction can be rerun offline
+// The snapshot is kept in the gzip header, making each cache file self-describing
+func CachePage(snapshot Snapshot, htmlContent string) {
+	if !CachePages {
+		return
+	}
+	if err := os.MkdirAll(CacheDir, os.ModePerm); err != nil {
+		slog.Error("Unable to create cache directory", "path", CacheDir, "error", err)
+		return
+	}
+	// The whole extra field, including the subfield's 4 byte header, has a 16 bit length
+	if len(snapshot.URL) > math.MaxUint16-4 {
+		slog.Error("Error caching page", "url", snapshot.URL, "error", "URL too long to store in the cache file")
+		return
+	}
+
+	var compressed bytes.Buffer
+	compressor := gzip.NewWriter(&compressed)
+	compressor.Name = snapshot.Timestamp
+	extra := binary.LittleEndian.AppendUint16([]byte{cacheURLField[0], cacheURLField[1]}, uint16(len(snapshot.URL)))
+	compressor.Extra = append(extra, snapshot.URL...)
+	_, err := io.WriteString(compressor, htmlContent)
+	if err == nil {
+		err = compressor.Close()
+	}
+	if err == nil {
+		_, err = WriteFileAtomic(&compressed, cachePath(snapshot), int64(compressed.Len()))
+	}
+	if err != nil {
+		slog.Error("Error caching page", "url", snapshot.URL, "error", err)
+	}
+}
+
+// ReadCachedPage returns the snapshot and HTML stored in a cache file
+func ReadCachedPage(path string) (Snapshot, string, error) {
+	cacheFile, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, "", err
+	}
+	defer cacheFile.Close()
+
+	decompressor, err := gzip.NewReader(cacheFile)
+	if err != nil {
+		return Snapshot{}, "", err
+	}
+	defer decompressor.Close()
+
+	body, err := io.ReadAll(decompressor)
+	if err != nil {
+		return Snapshot{}, "", err
+	}
+	return Snapshot{Timestamp: decompressor.Name, URL: cachedURL(decompressor.Header)}, string(body), nil
+}
+
+// cachedURL returns the page URL stored in a cache file's gzip header
+// Files cached before the URL moved to the extra field have it in the Comment
+func cachedURL(header gzip.Header) string {
+	extra := header.Extra
+	for len(extra) >= 4 {
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			break
+		}
+		if [2]byte(extra[:2]) == cacheURLField {
+			return string(extra[4 : 4+size])
+		}
+		extra = extra[4+size:]
+	}
+	return header.Comment
+}
+
+// CachedPagePaths lists the user's cached pages in capture order
+func CachedPagePaths() []string {
+	paths, err := filepath.Glob(filepath.Join(CacheDir, "*.html.gz"))
+	if err != nil {
+		return nil
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// reparseCache reruns image extraction over every cached page without any network requests
+func reparseCache() {
+	paths := CachedPagePaths()
+	slog.Info("=== Reparsing cached pages", "stage", "reparse", "pages", len(paths), "path", CacheDir)
+
+	PageMutex.Lock()
+	TotalPages = len(paths)
+	PageMutex.Unlock()
+	for _, path := range paths {
+		snapshot, htmlContent, err := ReadCachedPage(path)
+		if err != nil || strings.TrimSpace(snapshot.URL) == "" {
+			slog.Error("Error reading cached page", "stage", "reparse", "path", path, "error", err)
+			RecordFailure("reparse", path, fmt.Errorf("unreadable cache file: %v", err))
+			continue
+		}
+
+		extractImages(htmlContent, snapshot)
+		PageProcessed = append(PageProcessed, snapshot)
+		RecordPage(snapshot, "parsed", nil)
+	}
+
+	ImageMutex.Lock()
+	TotalImages = len(ImageUnprocessed)
+	DiscoveredImages = append([]string{}, ImageUnprocessed...)
+	ImageMutex.Unlock()
+	sort.Strings(DiscoveredImages)
+	slog.Info("Found images in cached pages", "stage", "reparse", "images", TotalImages, "username", TwitterUsername)
+}
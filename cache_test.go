@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"testing"
+)
+
+func TestCachePageRoundTrip(t *testing.T) {
+	previousCacheDir, previousCachePages := CacheDir, CachePages
+	t.Cleanup(func() { CacheDir, CachePages = previousCacheDir, previousCachePages })
+	CacheDir, CachePages = t.TempDir(), true
+
+	snapshot := Snapshot{Timestamp: "20150101000000", URL: "https://twitter.com/jack"}
+	CachePage(snapshot, "<html></html>")
+
+	path := cachePath(snapshot)
+	cached, html, err := ReadCachedPage(path)
+	if err != nil || cached != snapshot || html != "<html></html>" {
+		t.Errorf("ReadCachedPage = %+v, %q, %v, want the cached snapshot and HTML", cached, html, err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0644 {
+		t.Errorf("cache file mode = %v, want 0644", info.Mode().Perm())
+	}
+}
+
+func TestCachePageKeepsAnyURL(t *testing.T) {
+	previousCacheDir, previousCachePages := CacheDir, CachePages
+	t.Cleanup(func() { CacheDir, CachePages = previousCacheDir, previousCachePages })
+	CacheDir, CachePages = t.TempDir(), true
+
+	// Characters outside Latin-1 cannot go in a gzip comment
+	snapshot := Snapshot{Timestamp: "20150101000000", URL: "https://twitter.com/jack/status/20?q=日本語"}
+	CachePage(snapshot, "<html></html>")
+	if cached, _, err := ReadCachedPage(cachePath(snapshot)); err != nil || cached != snapshot {
+		t.Errorf("ReadCachedPage = %+v, %v, want %+v", cached, err, snapshot)
+	}
+
+	// Pages cached before the URL moved out of the comment still read back
+	legacy := Snapshot{Timestamp: "20160101000000", URL: "https://twitter.com/jack"}
+	var compressed bytes.Buffer
+	compressor := gzip.NewWriter(&compressed)
+	compressor.Name, compressor.Comment = legacy.Timestamp, legacy.URL
+	compressor.Write([]byte("<html></html>"))
+	compressor.Close()
+	if err := os.WriteFile(cachePath(legacy), compressed.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _, err := ReadCachedPage(cachePath(legacy)); err != nil || cached != legacy {
+		t.Errorf("ReadCachedPage of a legacy file = %+v, %v, want %+v", cached, err, legacy)
+	}
+}
+
+func TestReparseDoesNotInflateSightings(t *testing.T) {
+	resetScrapeState(t, newFakeWayback(t))
+	TwitterUsername = "jack"
+	CreateDirectories()
+
+	const media = "https://pbs.twimg.com/media/GOOD.jpg"
+	CachePage(Snapshot{Timestamp: "20150101000000", URL: "https://twitter.com/jack"}, `<html><body><img src="`+media+`"></body></html>`)
+
+	for range 2 {
+		ResetRunState()
+		LoadProvenance()
+		reparseCache()
+		SaveProvenance()
+	}
+
+	ResetRunState()
+	LoadProvenance()
+	if provenance := ImageProvenance[media]; provenance == nil || provenance.Sightings != 1 {
+		t.Errorf("provenance after reparsing twice = %+v, want 1 sighting", provenance)
+	}
+}
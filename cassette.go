@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// Cassette is a directory of recorded HTTP exchanges that a run can be replayed from
+// Each request's responses are numbered in the order they were made, i.e. a 429 then the 200 that followed,
+// so a replay sees the same retries the recorded run did
+type Cassette struct {
+	Dir       string
+	mutex     sync.Mutex
+	sequences map[string]int // Request key -> responses recorded or replayed so far
+}
+
+func OpenCassette(dir string) (*Cassette, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &Cassette{Dir: dir, sequences: make(map[string]int)}, nil
+}
+
+// next returns the sequence number of a request's next exchange
+func (c *Cassette) next(key string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.sequences[key] += 1
+	return c.sequences[key]
+}
+
+func (c *Cassette) path(key string, sequence int, extension string) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("%s.%d%s", key, sequence, extension))
+}
+
+// logExchange appends an exchange to the cassette's index so a recording can be read without decoding every file
+func (c *Cassette) logExchange(file string, outcome string, req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	index, err := os.OpenFile(filepath.Join(c.Dir, "index.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		slog.Error("Error writing cassette index", "path", c.Dir, "error", err)
+		return
+	}
+	defer index.Close()
+	fmt.Fprintf(index, "%s\t%s\t%s %s\n", file, outcome, req.Method, req.URL)
+}
+
+// Record stores the outcome of a request, a response or the transport error that replaced it
+func (c *Cassette) Record(req *http.Request, resp *http.Response, fetchErr error) (*http.Response, error) {
+	key := requestKey(req)
+	sequence := c.next(key)
+
+	if fetchErr != nil {
+		path := c.path(key, sequence, ".err")
+		if err := os.WriteFile(path, []byte(fetchErr.Error()), 0644); err != nil {
+			slog.Error("Error recording exchange", "url", req.URL.String(), "error", err)
+		}
+		c.logExchange(filepath.Base(path), "error", req)
+		return nil, fetchErr
+	}
+
+	path := c.path(key, sequence, ".http")
+	stored, err := StoreResponse(path, resp)
+	if err != nil {
+		return nil, fmt.Errorf("recording response: %w", err)
+	}
+	c.logExchange(filepath.Base(path), resp.Status, req)
+	return stored, nil
+}
+
+// Replay returns the recorded outcome of a request's next exchange
+// Once a request's recordings run out its last one is repeated, so extra retries still get an answer
+func (c *Cassette) Replay(req *http.Request) (*http.Response, error) {
+	key := requestKey(req)
+	sequence := c.next(key)
+
+	for ; sequence > 0; sequence-- {
+		if resp, err := LoadResponse(c.path(key, sequence, ".http"), req); err == nil {
+			return resp, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if message, err := os.ReadFile(c.path(key, sequence, ".err")); err == nil {
+			return nil, fmt.Errorf("recorded error: %s", strings.TrimSpace(string(message)))
+		}
+	}
+	return nil, ErrNotInCassette
+}
+
+// RecordingFetcher records every exchange made through the fetcher it wraps
+type RecordingFetcher struct {
+	Fetcher
+	Cassette *Cassette
+}
+
+func (f *RecordingFetcher) Do(req *http.Request) (*http.Response, error) {
+	resp, err := f.Fetcher.Do(req)
+	return f.Cassette.Record(req, resp, err)
+}
+
+// ReplayFetcher answers every request from a cassette without touching the network
+type ReplayFetcher struct {
+	Cassette *Cassette
+}
+
+func (f *ReplayFetcher) Do(req *http.Request) (*http.Response, error) { return f.Cassette.Replay(req) }
+func (f *ReplayFetcher) SetProxy(proxyURL string) error               { return nil }
+func (f *ReplayFetcher) GetProxy() string                             { return "" }
+
+// OpenRunCassette opens the cassette for recording or replaying the run's HTTP exchanges
+func OpenRunCassette() {
+	if RecordDir == "" && ReplayDir == "" {
+		return
+	}
+	if RecordDir != "" && ReplayDir != "" {
+		Fatal("Choose either -record or -replay, not both")
+	}
+
+	dir := RecordDir
+	if ReplayDir != "" {
+		dir = ReplayDir
+	}
+
+	// Sequence numbers restart with every run, so recordings are never mixed in one cassette
+	_, err := os.Stat(filepath.Join(dir, "index.txt"))
+	if ReplayDir != "" && err != nil {
+		Fatal("No recorded exchanges found", "path", dir)
+	}
+	if RecordDir != "" && err == nil {
+		Fatal("Directory already contains a recording, choose an empty directory", "path", dir)
+	}
+
+	cassette, err := OpenCassette(dir)
+	if err != nil {
+		Fatal("Error opening cassette", "path", dir, "error", err)
+	}
+	RunCassette = cassette
+
+	if ReplayDir != "" {
+		slog.Info("Replaying HTTP exchanges", "path", dir)
+	} else {
+		slog.Info("Recording HTTP exchanges", "path", dir)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCassetteReplaysRecordedRun(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+
+	const (
+		flaky   = "https://pbs.twimg.com/media/FLAKY.jpg"
+		limited = "https://pbs.twimg.com/media/LIMITED.jpg"
+		missing = "https://pbs.twimg.com/media/MISSING.jpg"
+	)
+	jpegData := fakeJPEG(t)
+	fake.AddPage("20150101000000", "https://twitter.com/jack", `<html><body><img src="`+flaky+`"><img src="`+limited+`"><img src="`+missing+`"></body></html>`)
+	fake.AddResponse(flaky, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData, Archived: true, Drops: 1})
+	fake.AddResponse(limited, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData, Archived: true, RateLimits: 1})
+
+	cassette := filepath.Join(t.TempDir(), "cassette")
+	RecordDir = cassette
+	runTestScrape()
+	recorded := ImageRecords
+
+	// Replay into a fresh directory with the archive gone
+	fake.Server.Close()
+	resetScrapeState(t, fake)
+	ReplayDir = cassette
+	runTestScrape()
+
+	if TotalPages != 1 || TotalImages != 3 {
+		t.Errorf("replay found %d pages and %d images, want 1 and 3", TotalPages, TotalImages)
+	}
+	if len(ImageRecords) != len(recorded) || TotalDownloads != 2 {
+		t.Errorf("replay made %d image records with %d downloads, recording made %d", len(ImageRecords), TotalDownloads, len(recorded))
+	}
+	for _, name := range []string{"FLAKY.jpg", "LIMITED.jpg"} {
+		if _, err := os.Stat(filepath.Join(MediaDir, name)); err != nil {
+			t.Errorf("replay did not save %s: %v", name, err)
+		}
+	}
+	if Latencies["images"].Requests != 5 {
+		t.Errorf("replay made %d image requests, want the recorded 5 including retries", Latencies["images"].Requests)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Matches the tweet ID in a status URL, i.e. twitter.com/jack/status/20
+var tweetRegex = regexp.MustCompile(`/status(?:es)?/([0-9]+)`)
+
+const catalogSchema = `
+CREATE TABLE IF NOT EXISTS accounts (
+	id            INTEGER PRIMARY KEY,
+	username      TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	first_scraped TEXT NOT NULL,
+	last_scraped  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS runs (
+	id                INTEGER PRIMARY KEY,
+	account_id        INTEGER NOT NULL REFERENCES accounts(id),
+	started_at        TEXT NOT NULL,
+	ended_at          TEXT,
+	pages_found       INTEGER,
+	images_found      INTEGER,
+	images_downloaded INTEGER,
+	failures          INTEGER
+);
+CREATE TABLE IF NOT EXISTS snapshots (
+	id          INTEGER PRIMARY KEY,
+	account_id  INTEGER NOT NULL REFERENCES accounts(id),
+	timestamp   TEXT NOT NULL,
+	url         TEXT NOT NULL,
+	captured_at TEXT NOT NULL,
+	UNIQUE (account_id, timestamp, url)
+);
+CREATE TABLE IF NOT EXISTS pages (
+	id          INTEGER PRIMARY KEY,
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots(id),
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	status      TEXT NOT NULL,
+	reason      TEXT
+);
+CREATE TABLE IF NOT EXISTS tweets (
+	id          INTEGER PRIMARY KEY,
+	account_id  INTEGER NOT NULL REFERENCES accounts(id),
+	tweet_id    TEXT NOT NULL,
+	url         TEXT NOT NULL,
+	first_seen  TEXT NOT NULL,
+	UNIQUE (account_id, tweet_id)
+);
+CREATE TABLE IF NOT EXISTS media (
+	id         INTEGER PRIMARY KEY,
+	account_id INTEGER NOT NULL REFERENCES accounts(id),
+	url        TEXT NOT NULL,
+	resource   TEXT NOT NULL,
+	filename   TEXT NOT NULL,
+	status     TEXT,
+	reason     TEXT,
+	path       TEXT,
+	size       INTEGER,
+	sha256     TEXT,
+	UNIQUE (account_id, url)
+);
+CREATE TABLE IF NOT EXISTS media_sightings (
+	media_id    INTEGER NOT NULL REFERENCES media(id),
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots(id),
+	PRIMARY KEY (media_id, snapshot_id)
+);
+CREATE INDEX IF NOT EXISTS snapshots_captured_at ON snapshots (captured_at);
+`
+
+// OpenCatalog opens (creating if needed) the SQLite catalog at path
+func OpenCatalog(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path))
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, the scraper's goroutines queue on this one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(catalogSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func catalogError(action string, err error) {
+	if err != nil {
+		slog.Error("Error "+action+" in catalog", "catalog", CatalogPath, "error", err)
+	}
+}
+
+// StartCatalogRun opens the catalog when CatalogPath is set and records the account and run
+func StartCatalogRun() {
+	if CatalogPath == "" {
+		return
+	}
+
+	db, err := OpenCatalog(CatalogPath)
+	if err != nil {
+		slog.Error("Error opening catalog", "catalog", CatalogPath, "error", err)
+		return
+	}
+
+	started := RunStarted.UTC().Format(time.RFC3339)
+	_, err = db.Exec(`INSERT INTO accounts (username, first_scraped, last_scraped) VALUES (?, ?, ?)
+		ON CONFLICT (username) DO UPDATE SET last_scraped = excluded.last_scraped`, TwitterUsername, started, started)
+	if err != nil {
+		catalogError("recording account", err)
+		db.Close()
+		return
+	}
+	if err := db.QueryRow(`SELECT id FROM accounts WHERE username = ?`, TwitterUsername).Scan(&CatalogAccountID); err != nil {
+		catalogError("reading account", err)
+		db.Close()
+		return
+	}
+
+	result, err := db.Exec(`INSERT INTO runs (account_id, started_at) VALUES (?, ?)`, CatalogAccountID, started)
+	if err != nil {
+		catalogError("recording run", err)
+		db.Close()
+		return
+	}
+	CatalogRunID, _ = result.LastInsertId()
+
+	Catalog = db
+	slog.Info("Recording run in catalog", "catalog", CatalogPath)
+}
+
+func catalogSnapshotID(snapshot Snapshot) (int64, error) {
+	capturedAt := snapshot.Time().Format(time.RFC3339)
+	_, err := Catalog.Exec(`INSERT OR IGNORE INTO snapshots (account_id, timestamp, url, captured_at) VALUES (?, ?, ?, ?)`,
+		CatalogAccountID, snapshot.Timestamp, snapshot.URL, capturedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = Catalog.QueryRow(`SELECT id FROM snapshots WHERE account_id = ? AND timestamp = ? AND url = ?`,
+		CatalogAccountID, snapshot.Timestamp, snapshot.URL).Scan(&id)
+	return id, err
+}
+
+func catalogMediaID(imageURL string) (int64, error) {
+	_, err := Catalog.Exec(`INSERT OR IGNORE INTO media (account_id, url, resource, filename) VALUES (?, ?, ?, ?)`,
+		CatalogAccountID, imageURL, ImageResource(imageURL), ImageFilename(imageURL))
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = Catalog.QueryRow(`SELECT id FROM media WHERE account_id = ? AND url = ?`, CatalogAccountID, imageURL).Scan(&id)
+	return id, err
+}
+
+// CatalogSnapshots records every snapshot found in the CDX index, and the tweets their URLs point at
+func CatalogSnapshots(snapshots []Snapshot) {
+	if Catalog == nil {
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		if _, err := catalogSnapshotID(snapshot); err != nil {
+			catalogError("recording snapshot", err)
+			continue
+		}
+
+		match := tweetRegex.FindStringSubmatch(snapshot.URL)
+		if match == nil {
+			continue
+		}
+		_, err := Catalog.Exec(`INSERT INTO tweets (account_id, tweet_id, url, first_seen) VALUES (?, ?, ?, ?)
+			ON CONFLICT (account_id, tweet_id) DO UPDATE SET first_seen = min(first_seen, excluded.first_seen)`,
+			CatalogAccountID, match[1], snapshot.URL, snapshot.Time().Format(time.RFC3339))
+		catalogError("recording tweet", err)
+	}
+}
+
+// CatalogPage records the outcome of visiting a snapshot in this run
+func CatalogPage(record PageRecord) {
+	if Catalog == nil {
+		return
+	}
+
+	snapshotID, err := catalogSnapshotID(Snapshot{Timestamp: record.Timestamp, URL: record.URL})
+	if err != nil {
+		catalogError("recording page", err)
+		return
+	}
+	_, err = Catalog.Exec(`INSERT INTO pages (snapshot_id, run_id, status, reason) VALUES (?, ?, ?, ?)`,
+		snapshotID, CatalogRunID, record.Status, record.Reason)
+	catalogError("recording page", err)
+}
+
+// CatalogSighting links an image to a snapshot it appeared in
+func CatalogSighting(imageURL string, snapshot Snapshot) {
+	if Catalog == nil {
+		return
+	}
+
+	mediaID, err := catalogMediaID(imageURL)
+	if err != nil {
+		catalogError("recording media", err)
+		return
+	}
+	snapshotID, err := catalogSnapshotID(snapshot)
+	if err != nil {
+		catalogError("recording snapshot", err)
+		return
+	}
+	_, err = Catalog.Exec(`INSERT OR IGNORE INTO media_sightings (media_id, snapshot_id) VALUES (?, ?)`, mediaID, snapshotID)
+	catalogError("recording sighting", err)
+}
+
+// CatalogImage records the download result for an image
+func CatalogImage(record ImageRecord) {
+	if Catalog == nil {
+		return
+	}
+
+	if _, err := catalogMediaID(record.URL); err != nil {
+		catalogError("recording media", err)
+		return
+	}
+	_, err := Catalog.Exec(`UPDATE media SET status = ?, reason = ?, path = ?, size = ?, sha256 = ? WHERE account_id = ? AND url = ?`,
+		record.Status, record.Reason, record.Path, record.Size, record.SHA256, CatalogAccountID, record.URL)
+	catalogError("recording media", err)
+}
+
+// FinishCatalogRun stores the run's totals and closes the catalog
+func FinishCatalogRun() {
+	if Catalog == nil {
+		return
+	}
+
+	report := BuildRunReport()
+	_, err := Catalog.Exec(`UPDATE runs SET ended_at = ?, pages_found = ?, images_found = ?, images_downloaded = ?, failures = ? WHERE id = ?`,
+		report.EndTime.UTC().Format(time.RFC3339), report.Counts["pages_found"], report.Counts["images_found"],
+		report.Counts["images_downloaded"], report.Counts["failures"], CatalogRunID)
+	catalogError("recording run totals", err)
+
+	Catalog.Close()
+	Catalog = nil
+}
+
+// QueryCatalog runs a read query against the catalog and prints the rows as a table
+func QueryCatalog(path string, query string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	// Read-only, so a query can neither change the catalog nor create its schema
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(10000)", path))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, strings.Join(columns, "\t"))
+
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		fields := make([]string, len(values))
+		for i, value := range values {
+			fields[i] = value.String
+		}
+		fmt.Fprintln(table, strings.Join(fields, "\t"))
+	}
+	table.Flush()
+	return rows.Err()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// useCatalog records runs in a fresh catalog for the rest of the test
+func useCatalog(t *testing.T) string {
+	previous := CatalogPath
+	t.Cleanup(func() { CatalogPath = previous })
+	CatalogPath = filepath.Join(t.TempDir(), "catalog.db")
+	return CatalogPath
+}
+
+func TestCatalogClosesRunWithoutPages(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+	path := useCatalog(t)
+
+	inputUsername("jack")
+	Scrape()
+	if Catalog != nil {
+		t.Error("catalog left open after a run that found no pages")
+	}
+
+	db, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var ended, pages any
+	if err := db.QueryRow(`SELECT ended_at, pages_found FROM runs`).Scan(&ended, &pages); err != nil {
+		t.Fatal(err)
+	}
+	if ended == nil || pages != int64(0) {
+		t.Errorf("run ended_at = %v, pages_found = %v, want the run closed with 0 pages", ended, pages)
+	}
+}
+
+func TestQueryCatalogIsReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.db")
+	db, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := QueryCatalog(path, `SELECT username FROM accounts`); err != nil {
+		t.Fatalf("read query failed: %v", err)
+	}
+	if err := QueryCatalog(path, `DELETE FROM accounts`); err == nil {
+		t.Error("query wrote to the catalog")
+	}
+
+	// A database that is not a catalog is queried as is, without the schema being added
+	other := filepath.Join(t.TempDir(), "other.db")
+	db, err = sql.Open("sqlite", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE notes (body TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if err := QueryCatalog(other, `SELECT body FROM notes`); err != nil {
+		t.Fatalf("read query failed: %v", err)
+	}
+	if err := QueryCatalog(other, `SELECT id FROM accounts`); err == nil {
+		t.Error("querying created the catalog schema")
+	}
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Commands maps each subcommand name to its handler, i.e. `waybackScraper timeline <username>`
+var Commands = map[string]func(args []string){
+	"timeline":   timelineCommand,
+	"validate":   validateCommand,
+	"diff":       diffCommand,
+	"gallery":    galleryCommand,
+	"thumbnails": thumbnailsCommand,
+	"bag":        bagCommand,
+	"verify":     verifyCommand,
+	"query":      queryCommand,
+	"reparse":    reparseCommand,
+	"stats":      statsCommand,
+	"serve":      serveCommand,
+	"watch":      watchCommand,
+	"config":     configCommand,
+	"help":       func(args []string) { printUsage() },
+}
+
+// ParseScrapeFlags reads the options for a normal scrape, i.e. `waybackScraper -warc <username>`
+func ParseScrapeFlags(args []string) {
+	flags := scrapeFlags("waybackScraper")
+	flags.Parse(args)
+	configure(flags)
+
+	if flags.NArg() > 0 {
+		TwitterUsername = flags.Arg(0)
+		if InvalidUsernameCheck() {
+			os.Exit(1)
+		}
+	}
+}
+
+// similarCommand returns the subcommand a bare word is a likely typo of, i.e. timeline for timelin,
+// so a mistyped command is not scraped as a username
+func similarCommand(word string) string {
+	word = strings.ToLower(word)
+	closest, closestDistance := "", 0
+	for name := range Commands {
+		allowed := 1
+		if len(name) >= 8 {
+			allowed = 2
+		}
+		distance := editDistance(word, name)
+		if distance <= allowed && (closest == "" || distance < closestDistance || distance == closestDistance && name < closest) {
+			closest, closestDistance = name, distance
+		}
+	}
+	return closest
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// scrapeFlags registers the scrape options, shared by a normal scrape and the serve command's job defaults
+func scrapeFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = printUsage
+	logFlags(flags)
+	flags.BoolVar(&WriteWARC, "warc", WriteWARC, "record every page and image fetch in a WARC file")
+	flags.BoolVar(&CSVReport, "csv", CSVReport, "also write a per-image CSV report")
+	flags.BoolVar(&DecodeImages, "decode", DecodeImages, "fully decode images when validating")
+	flags.BoolVar(&EmbedMetadata, "embed-metadata", EmbedMetadata, "write XMP provenance into downloaded JPEGs")
+	flags.BoolVar(&CachePages, "cache", CachePages, "keep gzipped HTML of parsed pages for the reparse command")
+	flags.StringVar(&CatalogPath, "catalog", CatalogPath, "record the run in a SQLite catalog at this path")
+	flags.BoolVar(&ShowDashboard, "dashboard", ShowDashboard, "draw live progress bars and recent events instead of scrolling logs")
+	flags.DurationVar(&ProgressInterval, "progress-interval", ProgressInterval, "how often -dashboard logs a progress summary when not on a terminal")
+	flags.StringVar(&MetricsAddr, "metrics", MetricsAddr, "serve Prometheus metrics on this address, i.e. :9090")
+	flags.StringVar(&Transport, "transport", Transport, "HTTP client to use, tls or net")
+	flags.StringVar(&ClientProfile, "profile", ClientProfile, "tls-client browser profile, i.e. chrome_120")
+	flags.StringVar(&HTTPCacheDir, "http-cache", HTTPCacheDir, "serve repeat requests from responses stored in this directory")
+	flags.StringVar(&RecordDir, "record", RecordDir, "record every HTTP exchange to this cassette directory")
+	flags.StringVar(&ReplayDir, "replay", ReplayDir, "replay a recorded cassette directory instead of using the network")
+	flags.Var(stringList{&WebhookURLs}, "webhook", "POST run events as JSON to this URL (repeatable)")
+	flags.Var(stringList{&HookCommands}, "hook-command", "run this shell command with each run event on stdin (repeatable)")
+	flags.Var(commaList{&HookEvents}, "hook-events", "comma separated events to fire hooks for, default all: "+strings.Join(HookEventNames, ", "))
+	flags.IntVar(&WebhookRetries, "webhook-retries", WebhookRetries, "times to retry a failed webhook delivery")
+	flags.Var(stringList{&ArchivePaths}, "archive", "read from a local WARC/WACZ file or directory instead of the Wayback Machine (repeatable)")
+	flags.IntVar(&MaxThreads, "threads", MaxThreads, "concurrent page and image requests")
+	flags.IntVar(&RetryAttempts, "retries", RetryAttempts, "attempts per page and image before requeuing it")
+	flags.StringVar(&ProxyFile, "proxies", ProxyFile, "file of proxies, one ip:port:username:password per line")
+	flags.Var(commaList{&Resources}, "resources", "comma separated resources to download, media, profile and banner")
+	flags.StringVar(&WaybackHost, "wayback-host", WaybackHost, "base URL of the Wayback Machine")
+	flags.StringVar(&WaybackPrefix, "wayback-prefix", WaybackPrefix, "path images are requested under, before their original URL")
+	flags.Var(patternFlag{&MediaRegex}, "media-pattern", "regular expression matching media image URLs")
+	flags.Var(patternFlag{&ProfileRegex}, "profile-pattern", "regular expression matching avatar URLs")
+	flags.Var(patternFlag{&BannerRegex}, "banner-pattern", "regular expression matching banner URLs")
+	return flags
+}
+
+// logFlags registers the logging and config file options every command accepts
+func logFlags(flags *flag.FlagSet) {
+	flags.StringVar(&LogFormat, "log-format", LogFormat, "log output format, text or json")
+	flags.StringVar(&LogLevel, "log-level", LogLevel, "minimum log level, debug, info, warn or error")
+	flags.BoolVar(&QuietLogs, "quiet", QuietLogs, "only log warnings and errors")
+	flags.StringVar(&ConfigPath, "config", ConfigPath, "read settings from this YAML file (default wayback.yaml in the working directory)")
+	flags.StringVar(&ConfigProfile, "config-profile", ConfigProfile, "apply a named group of settings, i.e. gentle or aggressive")
+}
+
+// configure fills in options not given as flags from the environment and config file, applies the logging options
+// and exits if any option is invalid
+func configure(flags *flag.FlagSet) {
+	if err := LoadConfig(flags); err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if err := ConfigureLogging(); err != nil {
+		slog.Error("Invalid logging options", "error", err)
+		os.Exit(1)
+	}
+	if err := ValidateTransport(); err != nil {
+		slog.Error("Invalid transport options", "error", err)
+		os.Exit(1)
+	}
+	if err := ValidateHooks(); err != nil {
+		slog.Error("Invalid hook options", "error", err)
+		os.Exit(1)
+	}
+	if err := ValidateSettings(); err != nil {
+		slog.Error("Invalid settings", "error", err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  waybackScraper [flags] [username]   Scrape a Twitter username (prompted if omitted)")
+	fmt.Println("  waybackScraper scrape [flags] [username]  The same, for usernames that look like a command")
+	fmt.Println("      -warc            record every page and image fetch in a WARC file")
+	fmt.Println("      -csv             also write a per-image CSV report")
+	fmt.Println("      -decode          fully decode images when validating")
+	fmt.Println("      -embed-metadata  write XMP provenance into downloaded JPEGs")
+	fmt.Println("      -cache=false     do not keep gzipped HTML of parsed pages")
+	fmt.Println("      -catalog path    record the run in a SQLite catalog at this path")
+	fmt.Println("      -log-format name text (default) or json")
+	fmt.Println("      -log-level name  minimum log level, debug, info (default), warn or error")
+	fmt.Println("      -quiet           only log warnings and errors")
+	fmt.Println("      -dashboard       draw live progress bars and recent events instead of scrolling logs")
+	fmt.Println("      -progress-interval 10s  how often -dashboard logs a summary when not on a terminal")
+	fmt.Println("      -metrics addr    serve Prometheus metrics on this address, i.e. :9090")
+	fmt.Println("      -transport name  HTTP client to use, tls (default) or net")
+	fmt.Println("      -profile name    tls-client browser profile, i.e. chrome_120 (default firefox_120)")
+	fmt.Println("      -http-cache dir  serve repeat requests from responses stored in this directory")
+	fmt.Println("      -record dir      record every HTTP exchange to this cassette directory")
+	fmt.Println("      -replay dir      replay a recorded cassette directory instead of using the network")
+	fmt.Println("      -archive path    read from a local WARC/WACZ file or directory instead of the Wayback Machine (repeatable)")
+	fmt.Println("      -config path     read settings from this YAML file (default wayback.yaml in the working directory)")
+	fmt.Println("      -config-profile name  apply a named group of settings, i.e. gentle or aggressive")
+	fmt.Println("      -threads 50      concurrent page and image requests")
+	fmt.Println("      -retries 5       attempts per page and image before requeuing it")
+	fmt.Println("      -proxies path    file of proxies (default proxies/proxies.txt)")
+	fmt.Println("      -resources list  comma separated resources to download, media, profile and banner")
+	fmt.Println("      -wayback-host url, -wayback-prefix path  where pages and images are requested from")
+	fmt.Println("      -media-pattern, -profile-pattern, -banner-pattern regexp  what counts as each resource's URL")
+	fmt.Println("      -webhook url     POST run events as JSON to this URL (repeatable)")
+	fmt.Println("      -hook-command cmd run this shell command with each run event on stdin (repeatable)")
+	fmt.Println("      -hook-events list comma separated events to fire hooks for: run.start, run.complete, image.saved, image.quarantined, run.error")
+	fmt.Println("      -webhook-retries 3  times to retry a failed webhook delivery")
+	fmt.Println("  Every command below also accepts -log-format, -log-level, -quiet, -config and -config-profile")
+	fmt.Println("  waybackScraper timeline <username>  Build an avatar and banner history timeline")
+	fmt.Println("  waybackScraper diff <username>      Show what changed since the previous run")
+	fmt.Println("  waybackScraper gallery <username>   Build an offline HTML gallery of downloaded files")
+	fmt.Println("  waybackScraper thumbnails <username> Generate thumbnails for downloaded images")
+	fmt.Println("  waybackScraper bag <username>       Package the user directory as a BagIt bag")
+	fmt.Println("  waybackScraper verify <bag>         Verify a bag's checksums")
+	fmt.Println("  waybackScraper query <catalog> <sql> Query a SQLite catalog")
+	fmt.Println("  waybackScraper reparse <username>   Rerun image extraction over cached pages and download new images")
+	fmt.Println("  waybackScraper stats <username>     Print statistics from the latest report")
+	fmt.Println("  waybackScraper validate <username>  Quarantine corrupted files (-decode to fully decode images)")
+	fmt.Println("  waybackScraper watch [flags] <username>...  Re-scrape accounts every interval, processing only new captures")
+	fmt.Println("      -interval 6h     time between checks")
+	fmt.Println("      -list path       file of usernames to watch, one per line")
+	fmt.Println("      -once            check each account once and exit")
+	fmt.Println("  waybackScraper config show [flags]  Print the effective settings and where each came from")
+	fmt.Println("  waybackScraper serve [flags]        Run scrape jobs submitted over a REST API, scrape flags set the job defaults")
+	fmt.Println("      -addr addr       address to serve the API on (default localhost:8080)")
+	fmt.Println("      -jobs path       file jobs are persisted to (default jobs.json in the working directory)")
+}
+
+// commandUser validates the username argument of a subcommand and points the directory variables at it
+func commandUser(flags *flag.FlagSet) {
+	configure(flags)
+	if flags.NArg() < 1 {
+		slog.Error(fmt.Sprintf("Usage: waybackScraper %s <username>", flags.Name()))
+		os.Exit(1)
+	}
+
+	TwitterUsername = flags.Arg(0)
+	if InvalidUsernameCheck() {
+		os.Exit(1)
+	}
+	CreateDirectories()
+}
+
+func timelineCommand(args []string) {
+	flags := flag.NewFlagSet("timeline", flag.ExitOnError)
+	logFlags(flags)
+	flags.Parse(args)
+	commandUser(flags)
+
+	LoadProvenance()
+	createTimeline()
+}
+
+func validateCommand(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	logFlags(flags)
+	flags.BoolVar(&DecodeImages, "decode", DecodeImages, "fully decode images to detect truncation")
+	flags.Parse(args)
+	commandUser(flags)
+
+	purgeCorrupted()
+}
+
+func diffCommand(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	logFlags(flags)
+	previous := flags.String("previous", "", "previous JSON report (defaults to the second latest)")
+	current := flags.String("current", "", "current JSON report (defaults to the latest)")
+	flags.Parse(args)
+	commandUser(flags)
+
+	createDiffReport(*previous, *current)
+}
+
+func galleryCommand(args []string) {
+	flags := flag.NewFlagSet("gallery", flag.ExitOnError)
+	logFlags(flags)
+	flags.Parse(args)
+	commandUser(flags)
+
+	LoadProvenance()
+	createGallery()
+}
+
+func thumbnailsCommand(args []string) {
+	flags := flag.NewFlagSet("thumbnails", flag.ExitOnError)
+	logFlags(flags)
+	flags.IntVar(&ThumbnailSize, "size", ThumbnailSize, "thumbnail width and height in pixels")
+	flags.IntVar(&ThumbnailThreads, "threads", ThumbnailThreads, "concurrent thumbnail workers")
+	flags.Parse(args)
+	commandUser(flags)
+
+	if ThumbnailSize < 1 || ThumbnailThreads < 1 {
+		slog.Error("Thumbnail size and threads must be at least 1", "size", ThumbnailSize, "threads", ThumbnailThreads)
+		os.Exit(1)
+	}
+
+	createThumbnails()
+}
+
+func bagCommand(args []string) {
+	flags := flag.NewFlagSet("bag", flag.ExitOnError)
+	logFlags(flags)
+	output := flags.String("out", filepath.Join(HomeDirectory, "bags"), "directory to create the bag in")
+	flags.Parse(args)
+	commandUser(flags)
+
+	createBag(*output)
+}
+
+func verifyCommand(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	logFlags(flags)
+	flags.Parse(args)
+	configure(flags)
+	if flags.NArg() < 1 {
+		slog.Error("Usage: waybackScraper verify <bag directory>")
+		os.Exit(1)
+	}
+
+	verifyBag(flags.Arg(0))
+}
+
+func queryCommand(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	logFlags(flags)
+	flags.Parse(args)
+	configure(flags)
+	if flags.NArg() < 2 {
+		slog.Error("Usage: waybackScraper query <catalog> <sql>")
+		os.Exit(1)
+	}
+
+	if err := QueryCatalog(flags.Arg(0), flags.Arg(1)); err != nil {
+		slog.Error("Error querying catalog", "catalog", flags.Arg(0), "error", err)
+		os.Exit(1)
+	}
+}
+
+func reparseCommand(args []string) {
+	flags := flag.NewFlagSet("reparse", flag.ExitOnError)
+	logFlags(flags)
+	download := flags.Bool("download", true, "download newly found images")
+	flags.Parse(args)
+	commandUser(flags)
+
+	RunStarted = time.Now()
+	LoadProvenance()
+	CreateStoredImageMap()
+	reparseCache()
+	RemoveCommonItems()
+	if *download {
+		LoadProxies()
+		downloadImages()
+		purgeCorrupted()
+		createThumbnails()
+	}
+	SaveProvenance()
+	createReport()
+	FlushHooks()
+}
+
+func statsCommand(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	logFlags(flags)
+	report := flags.String("report", "", "JSON report to summarise (defaults to the latest)")
+	flags.Parse(args)
+	commandUser(flags)
+
+	printLatestStats(*report)
+}
+
+func serveCommand(args []string) {
+	flags := scrapeFlags("serve")
+	flags.StringVar(&ServeAddr, "addr", ServeAddr, "address to serve the REST API on")
+	flags.StringVar(&JobsPath, "jobs", JobsPath, "file jobs are persisted to (default jobs.json in the working directory)")
+	flags.Parse(args)
+	configure(flags)
+
+	// Every job would record into the same cassette, which OpenRunCassette refuses
+	if RecordDir != "" {
+		slog.Error("-record cannot be used with serve")
+		os.Exit(1)
+	}
+	if JobsPath == "" {
+		JobsPath = filepath.Join(HomeDirectory, "jobs.json")
+	}
+
+	queue, err := OpenJobQueue(JobsPath, CurrentScrapeOptions())
+	if err != nil {
+		slog.Error("Error loading jobs", "path", JobsPath, "error", err)
+		os.Exit(1)
+	}
+
+	StartMetricsServer()
+	LoadProxies()
+	OpenLocalArchives()
+	FatalPanics = true
+	if err := ServeJobs(queue); err != nil {
+		slog.Error("Error serving REST API", "addr", ServeAddr, "error", err)
+		os.Exit(1)
+	}
+}
+
+func watchCommand(args []string) {
+	flags := scrapeFlags("watch")
+	flags.DurationVar(&WatchInterval, "interval", WatchInterval, "time between checks")
+	list := flags.String("list", "", "file of usernames to watch, one per line")
+	once := flags.Bool("once", false, "check each account once and exit")
+	flags.Parse(args)
+	configure(flags)
+
+	usernames := flags.Args()
+	if *list != "" {
+		listed, err := ReadWatchList(*list)
+		if err != nil {
+			slog.Error("Error reading watch list", "path", *list, "error", err)
+			os.Exit(1)
+		}
+		usernames = append(usernames, listed...)
+	}
+	if len(usernames) == 0 {
+		slog.Error("Usage: waybackScraper watch [-interval 6h] [-list file] <username>...")
+		os.Exit(1)
+	}
+	for i, username := range usernames {
+		TwitterUsername = username
+		if InvalidUsernameCheck() {
+			os.Exit(1)
+		}
+		usernames[i] = TwitterUsername
+	}
+	// Every account would record into the same cassette, which OpenRunCassette refuses
+	if RecordDir != "" {
+		slog.Error("-record cannot be used with watch")
+		os.Exit(1)
+	}
+
+	// Interrupting stops the current check without advancing its account's last seen timestamp
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	StartMetricsServer()
+	LoadProxies()
+	OpenLocalArchives()
+	for {
+		WatchAccounts(ctx, usernames)
+		if *once || ctx.Err() != nil {
+			return
+		}
+
+		slog.Info("Waiting for the next check", "accounts", len(usernames), "next", time.Now().Add(WatchInterval).Format(time.DateTime))
+		select {
+		case <-time.After(WatchInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func configCommand(args []string) {
+	if len(args) < 1 || args[0] != "show" {
+		slog.Error("Usage: waybackScraper config show [flags]")
+		os.Exit(1)
+	}
+
+	flags := scrapeFlags("config show")
+	flags.Parse(args[1:])
+	configure(flags)
+
+	if err := ShowConfig(); err != nil {
+		slog.Error("Error printing config", "error", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestSimilarCommand(t *testing.T) {
+	for word, want := range map[string]string{
+		"timelin":   "timeline",
+		"thumbnail": "thumbnails",
+		"Stats":     "stats",
+		"confg":     "config",
+		"jack":      "",
+		"bob":       "",
+		"0xf6i":     "",
+	} {
+		if got := similarCommand(word); got != want {
+			t.Errorf("similarCommand(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profiles every config file has, a profile of the same name in the file overrides their settings
+var BuiltinProfiles = map[string]map[string]any{
+	"gentle":     {"threads": 5, "retries": 8},
+	"aggressive": {"threads": 150, "retries": 3},
+}
+
+// stringList is a repeatable flag, i.e. -webhook a -webhook b
+type stringList struct{ values *[]string }
+
+func (l stringList) String() string {
+	if l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
+
+func (l stringList) Set(value string) error {
+	*l.values = append(*l.values, value)
+	return nil
+}
+
+// commaList is a flag holding a comma separated list, i.e. -resources media,profile
+type commaList struct{ values *[]string }
+
+func (l commaList) String() string { return stringList(l).String() }
+
+func (l commaList) Set(value string) error {
+	*l.values = strings.Split(value, ",")
+	return nil
+}
+
+// patternFlag is a flag holding a regular expression, compiled when set
+type patternFlag struct{ pattern **regexp.Regexp }
+
+func (p patternFlag) String() string {
+	if p.pattern == nil || *p.pattern == nil {
+		return ""
+	}
+	return (*p.pattern).String()
+}
+
+func (p patternFlag) Set(value string) error {
+	compiled, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+	*p.pattern = compiled
+	return nil
+}
+
+// configEnv is the environment variable overriding a setting, i.e. WAYBACK_LOG_LEVEL for -log-level
+func configEnv(name string) string {
+	return "WAYBACK_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// LoadConfig sets every option not given on the command line from the environment, the selected profile or the config file,
+// in that order of precedence. Settings are named after their flags, i.e. `log-level: debug` or WAYBACK_LOG_LEVEL=debug
+func LoadConfig(explicit *flag.FlagSet) error {
+	setByFlag := map[string]bool{}
+	explicit.Visit(func(f *flag.Flag) { setByFlag[f.Name] = true })
+	settings := scrapeFlags("config")
+
+	ConfigSources = map[string]string{}
+	for name := range setByFlag {
+		ConfigSources[name] = "flag"
+	}
+
+	if path, ok := os.LookupEnv(configEnv("config")); ok && !setByFlag["config"] {
+		ConfigPath = path
+	}
+	fileValues, fileProfiles, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+
+	if profile, ok := os.LookupEnv(configEnv("config-profile")); ok && !setByFlag["config-profile"] {
+		ConfigProfile = profile
+	} else if profile, ok := fileValues["config-profile"]; ok && !setByFlag["config-profile"] {
+		ConfigProfile = fmt.Sprint(profile)
+	}
+	delete(fileValues, "config")
+	delete(fileValues, "config-profile")
+
+	values, sources := map[string]any{}, map[string]string{}
+	for name, value := range fileValues {
+		values[name], sources[name] = value, "file"
+	}
+	if ConfigProfile != "" {
+		profile, err := mergeProfile(ConfigProfile, fileProfiles)
+		if err != nil {
+			return err
+		}
+		for name, value := range profile {
+			values[name], sources[name] = value, "profile "+ConfigProfile
+		}
+	}
+	settings.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "config-profile" {
+			return
+		}
+		if value, ok := os.LookupEnv(configEnv(f.Name)); ok {
+			values[f.Name], sources[f.Name] = value, "env "+configEnv(f.Name)
+		}
+	})
+
+	for _, name := range sortedKeys(values) {
+		if setByFlag[name] {
+			continue
+		}
+		if settings.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q from %s", name, sources[name])
+		}
+		if err := setConfigValue(settings, name, values[name]); err != nil {
+			return fmt.Errorf("invalid %s from %s: %w", name, sources[name], err)
+		}
+		ConfigSources[name] = sources[name]
+	}
+	return nil
+}
+
+// readConfigFile reads ConfigPath, or wayback.yaml in HomeDirectory if it exists, returning its settings and profiles
+func readConfigFile() (map[string]any, map[string]map[string]any, error) {
+	path := ConfigPath
+	if path == "" {
+		path = filepath.Join(HomeDirectory, "wayback.yaml")
+		if _, err := os.Stat(path); err != nil {
+			return map[string]any{}, nil, nil
+		}
+		ConfigPath = path
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var file map[string]any
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	values := normalizeSettings(file)
+	profiles := map[string]map[string]any{}
+	if raw, ok := values["profiles"]; ok {
+		named, ok := raw.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("reading %s: profiles should map names to settings", path)
+		}
+		for name, settings := range named {
+			profile, ok := settings.(map[string]any)
+			if !ok {
+				return nil, nil, fmt.Errorf("reading %s: profile %q should map settings to values", path, name)
+			}
+			profiles[name] = normalizeSettings(profile)
+		}
+		delete(values, "profiles")
+	}
+	return values, profiles, nil
+}
+
+// normalizeSettings lets config files spell settings with underscores, i.e. log_level for log-level
+func normalizeSettings(settings map[string]any) map[string]any {
+	normalized := make(map[string]any, len(settings))
+	for name, value := range settings {
+		normalized[strings.ReplaceAll(name, "_", "-")] = value
+	}
+	return normalized
+}
+
+// mergeProfile returns a built-in profile overlaid with the config file's profile of the same name
+func mergeProfile(name string, fileProfiles map[string]map[string]any) (map[string]any, error) {
+	builtin, isBuiltin := BuiltinProfiles[name]
+	custom, isCustom := fileProfiles[name]
+	if !isBuiltin && !isCustom {
+		available := sortedKeys(BuiltinProfiles)
+		for profile := range fileProfiles {
+			if !slices.Contains(available, profile) {
+				available = append(available, profile)
+			}
+		}
+		sort.Strings(available)
+		return nil, fmt.Errorf("unknown config profile %q, available: %s", name, strings.Join(available, ", "))
+	}
+
+	profile := map[string]any{}
+	for setting, value := range builtin {
+		profile[setting] = value
+	}
+	for setting, value := range custom {
+		profile[setting] = value
+	}
+	return profile, nil
+}
+
+// setConfigValue sets a flag from a config value, setting repeatable flags once per list item
+func setConfigValue(settings *flag.FlagSet, name string, value any) error {
+	switch value := value.(type) {
+	case []any:
+		if _, ok := settings.Lookup(name).Value.(stringList); ok {
+			for _, item := range value {
+				if err := settings.Set(name, fmt.Sprint(item)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item)
+		}
+		return settings.Set(name, strings.Join(items, ","))
+	case map[string]any:
+		return fmt.Errorf("expected a value, not a mapping")
+	default:
+		return settings.Set(name, fmt.Sprint(value))
+	}
+}
+
+// ValidateSettings checks the settings the config file opened up that have no other validation
+func ValidateSettings() error {
+	if MaxThreads < 1 {
+		return fmt.Errorf("threads must be at least 1, got %d", MaxThreads)
+	}
+	if RetryAttempts < 1 {
+		return fmt.Errorf("retries must be at least 1, got %d", RetryAttempts)
+	}
+	if ProgressInterval <= 0 {
+		return fmt.Errorf("progress-interval must be positive, got %s", ProgressInterval)
+	}
+	if WatchInterval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", WatchInterval)
+	}
+	for _, resource := range Resources {
+		if !slices.Contains([]string{"media", "profile", "banner"}, resource) {
+			return fmt.Errorf("unknown resource %q, available: media, profile, banner", resource)
+		}
+	}
+	return nil
+}
+
+// ShowConfig prints the effective settings as YAML, each commented with where it came from unless it is the default
+func ShowConfig() error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	if ConfigPath != "" {
+		root.HeadComment = "Config file: " + ConfigPath
+	}
+	if ConfigProfile != "" {
+		root.HeadComment = strings.TrimSpace(root.HeadComment + "\nProfile: " + ConfigProfile)
+	}
+
+	scrapeFlags("config").VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "config-profile" {
+			return
+		}
+
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.Value.String()}
+		switch typed := f.Value.(type) {
+		case stringList, commaList:
+			value = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for _, item := range strings.Split(typed.String(), ",") {
+				if item != "" {
+					value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+				}
+			}
+		case flag.Getter:
+			switch typed.Get().(type) {
+			case bool:
+				value.Tag = "!!bool"
+			case int:
+				value.Tag = "!!int"
+			}
+		}
+		if source, ok := ConfigSources[f.Name]; ok {
+			value.LineComment = source
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.Name}, value)
+	})
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// useConfigFile writes a config file and restores every setting the test may change when it ends
+func useConfigFile(t *testing.T, contents string) {
+	previousThreads, previousRetries, previousLevel, previousQuiet := MaxThreads, RetryAttempts, LogLevel, QuietLogs
+	previousWebhooks, previousResources, previousCSV := WebhookURLs, Resources, CSVReport
+	previousPath, previousProfile, previousHome := ConfigPath, ConfigProfile, HomeDirectory
+	t.Cleanup(func() {
+		MaxThreads, RetryAttempts, LogLevel, QuietLogs = previousThreads, previousRetries, previousLevel, previousQuiet
+		WebhookURLs, Resources, CSVReport = previousWebhooks, previousResources, previousCSV
+		ConfigPath, ConfigProfile, HomeDirectory = previousPath, previousProfile, previousHome
+	})
+
+	HomeDirectory = t.TempDir()
+	ConfigPath, ConfigProfile = "", ""
+	if err := os.WriteFile(filepath.Join(HomeDirectory, "wayback.yaml"), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	useConfigFile(t, `
+threads: 20
+retries: 4
+log_level: debug
+quiet: true
+resources: [media, banner]
+webhook:
+  - https://example.com/a
+  - https://example.com/b
+profiles:
+  gentle:
+    retries: 9
+`)
+	t.Setenv("WAYBACK_CONFIG_PROFILE", "gentle")
+	t.Setenv("WAYBACK_THREADS", "30")
+	t.Setenv("WAYBACK_LOG_LEVEL", "warn")
+
+	flags := scrapeFlags("test")
+	if err := flags.Parse([]string{"-log-level", "error", "-csv"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if MaxThreads != 30 {
+		t.Errorf("threads = %d, want 30 from the environment over the file", MaxThreads)
+	}
+	if RetryAttempts != 9 {
+		t.Errorf("retries = %d, want 9 from the gentle profile over the file", RetryAttempts)
+	}
+	if LogLevel != "error" {
+		t.Errorf("log level = %q, want error from the flag over the environment", LogLevel)
+	}
+	if !QuietLogs || !CSVReport {
+		t.Errorf("quiet = %v, csv = %v, want both set", QuietLogs, CSVReport)
+	}
+	if !slices.Equal(Resources, []string{"media", "banner"}) {
+		t.Errorf("resources = %v, want [media banner]", Resources)
+	}
+	if !slices.Equal(WebhookURLs, []string{"https://example.com/a", "https://example.com/b"}) {
+		t.Errorf("webhooks = %v, want both from the file", WebhookURLs)
+	}
+
+	want := map[string]string{"threads": "env WAYBACK_THREADS", "retries": "profile gentle", "log-level": "flag", "quiet": "file"}
+	for name, source := range want {
+		if ConfigSources[name] != source {
+			t.Errorf("%s came from %q, want %q", name, ConfigSources[name], source)
+		}
+	}
+}
+
+func TestLoadConfigBuiltinProfile(t *testing.T) {
+	useConfigFile(t, "")
+
+	flags := scrapeFlags("test")
+	flags.Parse([]string{"-config-profile", "aggressive"})
+	if err := LoadConfig(flags); err != nil {
+		t.Fatal(err)
+	}
+	if MaxThreads != 150 {
+		t.Errorf("threads = %d, want 150 from the built-in aggressive profile", MaxThreads)
+	}
+}
+
+func TestLoadConfigRejectsInvalidSettings(t *testing.T) {
+	for name, contents := range map[string]string{
+		"unknown setting": "thread_count: 5\n",
+		"invalid value":   "threads: many\n",
+		"unknown profile": "config_profile: reckless\n",
+		"invalid pattern": "media_pattern: \"[\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			useConfigFile(t, contents)
+			if err := LoadConfig(scrapeFlags("test")); err == nil {
+				t.Error("loaded an invalid config")
+			}
+		})
+	}
+}
+
+func TestValidateSettingsRejectsNonPositiveProgressInterval(t *testing.T) {
+	previous := ProgressInterval
+	t.Cleanup(func() { ProgressInterval = previous })
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		ProgressInterval = interval
+		if ValidateSettings() == nil {
+			t.Errorf("accepted a progress interval of %s", interval)
+		}
+	}
+}
+
+func TestValidateSettingsRejectsNonPositiveWatchInterval(t *testing.T) {
+	previous := WatchInterval
+	t.Cleanup(func() { WatchInterval = previous })
+
+	for _, interval := range []time.Duration{0, -time.Hour} {
+		WatchInterval = interval
+		if ValidateSettings() == nil {
+			t.Errorf("accepted a watch interval of %s", interval)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gookit/color"
+	"golang.org/x/term"
+)
+
+// Lines of log output kept in the dashboard's recent events pane
+const dashboardEvents = 10
+
+// Progress is a consistent read of the run's counters, each taken under its own mutex
+type Progress struct {
+	PagesDone       int   `json:"pages_done"`
+	PagesTotal      int   `json:"pages_total"`
+	ImagesDone      int   `json:"images_done"`
+	ImagesTotal     int   `json:"images_total"`
+	ImagesQueued    int   `json:"images_queued"`
+	Failures        int   `json:"failures"`
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+	ProxiesActive   int   `json:"proxies_active"`
+	ProxiesIdle     int   `json:"proxies_idle"`
+}
+
+func TakeProgress() Progress {
+	var progress Progress
+
+	PageMutex.Lock()
+	progress.PagesTotal = TotalPages
+	PageMutex.Unlock()
+
+	ImageMutex.Lock()
+	progress.ImagesQueued = len(ImageUnprocessed)
+	if TotalImages > 0 {
+		progress.ImagesTotal = TotalImages - FilteredImages
+	}
+	ImageMutex.Unlock()
+
+	// Records rather than the processed slices, so skipped pages and images count as done
+	ReportMutex.Lock()
+	progress.PagesDone = len(PageRecords)
+	progress.ImagesDone = len(ImageRecords)
+	progress.Failures = len(FailureRecords)
+	for _, record := range ImageRecords {
+		progress.BytesDownloaded += record.Size
+	}
+	ReportMutex.Unlock()
+
+	ProxyMutex.Lock()
+	progress.ProxiesActive = len(ProxiesActive)
+	progress.ProxiesIdle = len(Proxies)
+	ProxyMutex.Unlock()
+
+	return progress
+}
+
+// stageRate measures a stage's throughput from when it first made progress
+type stageRate struct {
+	started   time.Time
+	startDone int
+}
+
+// update returns items per second and the estimated time left, or zero when there is too little to go on
+func (r *stageRate) update(done int, total int, now time.Time) (float64, time.Duration) {
+	if r.started.IsZero() {
+		if total > 0 {
+			r.started, r.startDone = now, done
+		}
+		return 0, 0
+	}
+
+	elapsed := now.Sub(r.started).Seconds()
+	if elapsed < 1 || done <= r.startDone {
+		return 0, 0
+	}
+	rate := float64(done-r.startDone) / elapsed
+	return rate, time.Duration(float64(total-done) / rate * float64(time.Second))
+}
+
+// Dashboard redraws a fixed block of progress bars and recent log events in place
+// Log output is written to it instead of stdout while it runs
+type Dashboard struct {
+	mutex   sync.Mutex
+	events  []string
+	partial string
+	lines   int // Lines drawn by the previous frame, to move back over
+	started time.Time
+	pages   stageRate
+	images  stageRate
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Write collects log lines for the recent events pane
+func (d *Dashboard) Write(data []byte) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	text := d.partial + string(data)
+	lines := strings.Split(text, "\n")
+	d.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		d.events = append(d.events, line)
+	}
+	if len(d.events) > dashboardEvents {
+		d.events = d.events[len(d.events)-dashboardEvents:]
+	}
+	return len(data), nil
+}
+
+func (d *Dashboard) run(interval time.Duration) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.render()
+		select {
+		case <-ticker.C:
+		case <-d.stop:
+			d.render()
+			return
+		}
+	}
+}
+
+func (d *Dashboard) render() {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+
+	progress := TakeProgress()
+	now := time.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	pageRate, pageETA := d.pages.update(progress.PagesDone, progress.PagesTotal, now)
+	imageRate, imageETA := d.images.update(progress.ImagesDone, progress.ImagesTotal, now)
+
+	lines := []string{
+		progressLine("Pages", progress.PagesDone, progress.PagesTotal, pageRate, pageETA, width),
+		progressLine("Images", progress.ImagesDone, progress.ImagesTotal, imageRate, imageETA, width),
+		fmt.Sprintf("Queued images %d | Errors %s | Proxies %d active, %d idle | Downloaded %s | Elapsed %s",
+			progress.ImagesQueued, errorCount(progress.Failures), progress.ProxiesActive, progress.ProxiesIdle,
+			formatBytes(progress.BytesDownloaded), now.Sub(d.started).Round(time.Second)),
+		color.Gray.Sprint("── Recent events " + strings.Repeat("─", max(width-18, 0))),
+	}
+	for i := 0; i < dashboardEvents; i++ {
+		event := ""
+		if i < len(d.events) {
+			event = d.events[i]
+		}
+		lines = append(lines, event)
+	}
+
+	var frame strings.Builder
+	if d.lines > 0 {
+		fmt.Fprintf(&frame, "\x1b[%dF", d.lines) // Back to the start of the previous frame
+	}
+	for _, line := range lines {
+		frame.WriteString("\x1b[2K" + truncateVisible(line, width-1) + "\n")
+	}
+	os.Stdout.WriteString(frame.String())
+	d.lines = len(lines)
+}
+
+// progressLine draws a stage's bar, count, throughput and ETA
+func progressLine(stage string, done int, total int, rate float64, eta time.Duration, width int) string {
+	if total == 0 {
+		return fmt.Sprintf("%-7s waiting", stage)
+	}
+
+	barWidth := min(max(width-60, 10), 40)
+	fraction := min(float64(done)/float64(total), 1)
+	filled := int(fraction * float64(barWidth))
+	bar := color.Green.Sprint(strings.Repeat("█", filled)) + color.Gray.Sprint(strings.Repeat("░", barWidth-filled))
+
+	line := fmt.Sprintf("%-7s %s %3.0f%% %d/%d", stage, bar, fraction*100, done, total)
+	if rate > 0 {
+		line += fmt.Sprintf("  %.1f/s  ETA %s", rate, eta.Round(time.Second))
+	}
+	return line
+}
+
+func errorCount(failures int) string {
+	if failures == 0 {
+		return "0"
+	}
+	return color.Red.Sprint(failures)
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	divisor, exponent := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		divisor *= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(divisor), "KMGTPE"[exponent])
+}
+
+// truncateVisible cuts a line to width printed characters, passing colour escape sequences through uncounted
+func truncateVisible(line string, width int) string {
+	var output strings.Builder
+	visible := 0
+	escaped, bracketed := false, false
+	for _, character := range line {
+		switch {
+		case character == '\x1b':
+			escaped, bracketed = true, false
+		case escaped && character == '[' && !bracketed:
+			bracketed = true
+		case escaped:
+			// Sequences end with a letter, i.e. the m of \x1b[32m
+			escaped = character < '@' || character > '~'
+		case visible >= width:
+			output.WriteString("\x1b[0m")
+			return output.String()
+		default:
+			visible++
+		}
+		output.WriteRune(character)
+	}
+	return output.String()
+}
+
+// Stops whichever of the dashboard or the summary logger StartDashboard started
+var stopProgress = func() {}
+
+// StartDashboard shows live progress when -dashboard is set
+// Off a terminal, or when logging JSON, a summary is logged every ProgressInterval instead
+func StartDashboard() {
+	if !ShowDashboard {
+		return
+	}
+
+	if LogFormat != "text" || !term.IsTerminal(int(os.Stdout.Fd())) {
+		stop, done := make(chan struct{}), make(chan struct{})
+		go logProgress(stop, done)
+		stopProgress = func() {
+			close(stop)
+			<-done
+		}
+		return
+	}
+
+	dashboard := &Dashboard{started: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	RunDashboard = dashboard
+	ConfigureLogging()
+	go dashboard.run(250 * time.Millisecond)
+	stopProgress = func() {
+		close(dashboard.stop)
+		<-dashboard.done
+		RunDashboard = nil
+		ConfigureLogging()
+	}
+}
+
+// StopDashboard draws the final frame and returns log output to stdout
+func StopDashboard() {
+	stopProgress()
+	stopProgress = func() {}
+}
+
+// logProgress logs a progress summary every ProgressInterval until stopped
+func logProgress(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	var pages, images stageRate
+	ticker := time.NewTicker(ProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			progress := TakeProgress()
+			pageRate, pageETA := pages.update(progress.PagesDone, progress.PagesTotal, now)
+			imageRate, imageETA := images.update(progress.ImagesDone, progress.ImagesTotal, now)
+			slog.Info("Progress",
+				"pages", fmt.Sprintf("%d/%d", progress.PagesDone, progress.PagesTotal), "pages_per_second", math.Round(pageRate*10)/10, "pages_eta", pageETA.Round(time.Second),
+				"images", fmt.Sprintf("%d/%d", progress.ImagesDone, progress.ImagesTotal), "images_per_second", math.Round(imageRate*10)/10, "images_eta", imageETA.Round(time.Second),
+				"queued_images", progress.ImagesQueued, "errors", progress.Failures, "proxies_active", progress.ProxiesActive, "bytes", progress.BytesDownloaded)
+		}
+	}
+}
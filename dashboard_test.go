@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer lets the progress goroutine and the test share a log buffer
+type lockedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(data []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(data)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func TestDashboardLogsProgressOffTerminal(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+
+	previousDashboard, previousInterval, previousLogger := ShowDashboard, ProgressInterval, slog.Default()
+	t.Cleanup(func() {
+		ShowDashboard, ProgressInterval = previousDashboard, previousInterval
+		slog.SetDefault(previousLogger)
+	})
+
+	var output lockedBuffer
+	slog.SetDefault(slog.New(newConsoleHandler(&output, slog.LevelInfo, false)))
+	ShowDashboard, ProgressInterval = true, 5*time.Millisecond
+	TotalPages = 4
+	RecordPage(Snapshot{Timestamp: "20150101000000", URL: "https://twitter.com/jack"}, "parsed", nil)
+
+	// Test output is not a terminal, so summaries are logged rather than a dashboard drawn
+	StartDashboard()
+	if RunDashboard != nil {
+		t.Fatal("dashboard drawn when stdout is not a terminal")
+	}
+	time.Sleep(50 * time.Millisecond)
+	StopDashboard()
+
+	if !strings.Contains(output.String(), "Progress pages=1/4") {
+		t.Errorf("no progress summary logged: %q", output.String())
+	}
+}
+
+func TestStageRateEstimatesTimeLeft(t *testing.T) {
+	var rate stageRate
+	started := time.Now()
+
+	if perSecond, _ := rate.update(0, 100, started); perSecond != 0 {
+		t.Errorf("rate %v before any progress, want 0", perSecond)
+	}
+	perSecond, eta := rate.update(20, 100, started.Add(10*time.Second))
+	if perSecond != 2 || eta != 40*time.Second {
+		t.Errorf("rate %v with %s left, want 2/s with 40s left", perSecond, eta)
+	}
+}
+
+func TestTruncateVisibleIgnoresEscapes(t *testing.T) {
+	line := "\x1b[32mINFO\x1b[0m Saved image"
+	if got := truncateVisible(line, 8); got != "\x1b[32mINFO\x1b[0m Sav\x1b[0m" {
+		t.Errorf("truncateVisible = %q", got)
+	}
+	if got := truncateVisible(line, 80); got != line {
+		t.Errorf("short line changed to %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunDiff lists what changed between two runs of the same account
+type RunDiff struct {
+	Previous          string          `json:"previous"`
+	Current           string          `json:"current"`
+	NewSnapshots      []PageRecord    `json:"new_snapshots"`
+	NewImages         []ImageRecord   `json:"new_images"`
+	DisappearedImages []string        `json:"disappeared_images"` // Found or saved last run, missing from the archive now
+	NewFailures       []FailureRecord `json:"new_failures"`
+}
+
+func LoadRunReport(path string) (RunReport, error) {
+	var report RunReport
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+	err = json.Unmarshal(data, &report)
+	return report, err
+}
+
+// RunReportPaths returns the user's JSON reports, oldest first
+// Names only have the start time to the second, and a second run in the same second is named -1-report.json,
+// which sorts before -report.json, so reports are ordered by the start time recorded inside them
+func RunReportPaths() []string {
+	paths, err := filepath.Glob(filepath.Join(UsernameLocation, "*-report.json"))
+	if err != nil {
+		return nil
+	}
+
+	started := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		var report struct {
+			StartTime time.Time `json:"start_time"`
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &report)
+		}
+		started[path] = report.StartTime
+	}
+	sort.SliceStable(paths, func(i, j int) bool { return started[paths[i]].Before(started[paths[j]]) })
+	return paths
+}
+
+// DiffRuns compares a run against the one before it
+func DiffRuns(previous RunReport, current RunReport) RunDiff {
+	diff := RunDiff{
+		NewSnapshots:      []PageRecord{},
+		NewImages:         []ImageRecord{},
+		DisappearedImages: []string{},
+		NewFailures:       []FailureRecord{},
+	}
+
+	previousPages := make(map[string]bool)
+	for _, page := range previous.Pages {
+		previousPages[page.Timestamp+"/"+page.URL] = true
+	}
+	for _, page := range current.Pages {
+		if !previousPages[page.Timestamp+"/"+page.URL] {
+			diff.NewSnapshots = append(diff.NewSnapshots, page)
+		}
+	}
+
+	previousDownloads := make(map[string]bool)
+	for _, image := range previous.Images {
+		if image.Status == "downloaded" {
+			previousDownloads[image.URL] = true
+		}
+	}
+	for _, image := range current.Images {
+		if image.Status == "downloaded" && !previousDownloads[image.URL] {
+			diff.NewImages = append(diff.NewImages, image)
+		}
+	}
+
+	// An image has disappeared if it is no longer linked from any page
+	// A run that only parsed new captures did not see the older pages, so it cannot tell what is no longer linked
+	currentDiscovered := make(map[string]bool)
+	for _, imageURL := range current.Discovered {
+		currentDiscovered[imageURL] = true
+	}
+	disappeared := make(map[string]bool)
+	if len(current.Discovered) > 0 && current.Since == "" {
+		for _, imageURL := range previous.Discovered {
+			if !currentDiscovered[imageURL] {
+				disappeared[imageURL] = true
+			}
+		}
+	}
+	for imageURL := range disappeared {
+		diff.DisappearedImages = append(diff.DisappearedImages, imageURL)
+	}
+	sort.Strings(diff.DisappearedImages)
+
+	previousFailures := make(map[string]bool)
+	for _, failure := range previous.Failures {
+		previousFailures[failure.URL] = true
+	}
+	reported := make(map[string]bool)
+	for _, failure := range current.Failures {
+		if !previousFailures[failure.URL] && !reported[failure.URL] {
+			reported[failure.URL] = true
+			diff.NewFailures = append(diff.NewFailures, failure)
+		}
+	}
+
+	return diff
+}
+
+// createDiffReport compares the two given reports, or the latest two JSON reports if either is empty
+func createDiffReport(previousPath string, currentPath string) {
+	if previousPath == "" || currentPath == "" {
+		paths := RunReportPaths()
+		if len(paths) < 2 {
+			slog.Warn("Need at least two JSON reports to diff runs", "path", UsernameLocation)
+			return
+		}
+		previousPath, currentPath = paths[len(paths)-2], paths[len(paths)-1]
+	}
+
+	previous, err := LoadRunReport(previousPath)
+	if err != nil {
+		slog.Error("Error loading previous report", "path", previousPath, "error", err)
+		return
+	}
+	current, err := LoadRunReport(currentPath)
+	if err != nil {
+		slog.Error("Error loading current report", "path", currentPath, "error", err)
+		return
+	}
+
+	diff := DiffRuns(previous, current)
+	diff.Previous = previousPath
+	diff.Current = currentPath
+
+	data, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		slog.Error("Error encoding diff report", "error", err)
+		return
+	}
+
+	path := strings.TrimSuffix(currentPath, "-report.json") + "-diff.json"
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		slog.Error("Error writing diff report", "path", path, "error", err)
+		return
+	}
+
+	slog.Info("Changes since previous run", "previous", filepath.Base(previousPath), "new_snapshots", len(diff.NewSnapshots),
+		"new_images", len(diff.NewImages), "disappeared_images", len(diff.DisappearedImages), "new_failures", len(diff.NewFailures))
+	slog.Info("Diff report created", "path", path)
+}
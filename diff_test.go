@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRunReportPathsOrdersSameSecondRuns(t *testing.T) {
+	previous := UsernameLocation
+	t.Cleanup(func() { UsernameLocation = previous })
+	UsernameLocation = t.TempDir()
+
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, offset := range map[string]time.Duration{
+		"2023-12-31-235959-report.json":   -time.Second,
+		"2024-01-01-000000-report.json":   100 * time.Millisecond,
+		"2024-01-01-000000-1-report.json": 500 * time.Millisecond,
+	} {
+		data, _ := json.Marshal(RunReport{StartTime: started.Add(offset)})
+		if err := os.WriteFile(filepath.Join(UsernameLocation, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+	for _, path := range RunReportPaths() {
+		names = append(names, filepath.Base(path))
+	}
+	want := []string{"2023-12-31-235959-report.json", "2024-01-01-000000-report.json", "2024-01-01-000000-1-report.json"}
+	if !slices.Equal(names, want) {
+		t.Errorf("RunReportPaths = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Matches the path of a snapshot request, i.e. /web/20150101000000if_/https://twitter.com/jack
+var fakeSnapshotRegex = regexp.MustCompile(`^/web/([0-9]{14})[a-z_]*/(.+)$`)
+
+// fakeResponse is a canned archive response, served after Drops dropped connections and RateLimits 429s
+type fakeResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+	Archived    bool // Send the x-archive-* headers real captures carry
+	Drops       int
+	RateLimits  int
+}
+
+// fakeWayback is an offline stand-in for web.archive.org serving the timemap, snapshots and images
+type fakeWayback struct {
+	Server *httptest.Server
+
+	mutex     sync.Mutex
+	captures  map[string][]string // Original page URL -> capture timestamps for the timemap
+	responses map[string]*fakeResponse
+	hits      map[string]int
+}
+
+func newFakeWayback(t *testing.T) *fakeWayback {
+	fake := &fakeWayback{
+		captures:  make(map[string][]string),
+		responses: make(map[string]*fakeResponse),
+		hits:      make(map[string]int),
+	}
+	// A bare handler rather than a ServeMux, which would clean the "//" inside snapshot paths
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(fake.Server.Close)
+	return fake
+}
+
+// AddPage registers an HTML capture of a Twitter page that appears in the timemap
+func (f *fakeWayback) AddPage(timestamp string, original string, html string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.captures[original] = append(f.captures[original], timestamp)
+	f.responses[original] = &fakeResponse{Status: http.StatusOK, ContentType: "text/html; charset=utf-8", Body: []byte(html), Archived: true}
+}
+
+// AddTimemapEntry lists a capture in the timemap without a page behind it, so fetching it 404s
+func (f *fakeWayback) AddTimemapEntry(timestamp string, original string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.captures[original] = append(f.captures[original], timestamp)
+}
+
+// AddResponse registers the response for an original URL, whatever timestamp it is requested at
+func (f *fakeWayback) AddResponse(original string, response *fakeResponse) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.responses[original] = response
+}
+
+// Hits returns how many times an original URL has been requested
+func (f *fakeWayback) Hits(original string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.hits[original]
+}
+
+func (f *fakeWayback) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/web/timemap/json" {
+		f.serveTimemap(w, r)
+		return
+	}
+
+	match := fakeSnapshotRegex.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		http.NotFound(w, r)
+		return
+	}
+	timestamp, original := match[1], match[2]
+
+	f.mutex.Lock()
+	f.hits[original] += 1
+	response, ok := f.responses[original]
+	drop, rateLimit := false, false
+	if ok && response.Drops > 0 {
+		response.Drops -= 1
+		drop = true
+	} else if ok && response.RateLimits > 0 {
+		response.RateLimits -= 1
+		rateLimit = true
+	}
+	f.mutex.Unlock()
+
+	switch {
+	case !ok:
+		// The Wayback Machine answers uncaptured URLs with an HTML 404
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<!DOCTYPE html><html><body><p>Hrm.</p><p>Wayback Machine doesn't have that page archived.</p></body></html>"))
+	case drop:
+		// Simulate a connection reset part way through the exchange
+		connection, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			connection.Close()
+		}
+	case rateLimit:
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	default:
+		w.Header().Set("Content-Type", response.ContentType)
+		if response.Archived {
+			w.Header().Set("x-archive-src", "fake-archive.warc.gz")
+			w.Header().Set("x-archive-orig-content-type", response.ContentType)
+			w.Header().Set("Memento-Datetime", ParseWaybackTimestamp(timestamp).Format(http.TimeFormat))
+		}
+		w.WriteHeader(response.Status)
+		w.Write(response.Body)
+	}
+}
+
+func (f *fakeWayback) serveTimemap(w http.ResponseWriter, r *http.Request) {
+	prefix := archiveKey(r.URL.Query().Get("url"))
+
+	f.mutex.Lock()
+	rows := [][]string{{"urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"}}
+	for original, timestamps := range f.captures {
+		if !strings.HasPrefix(archiveKey(original), prefix) {
+			continue
+		}
+		for _, timestamp := range timestamps {
+			rows = append(rows, []string{archiveKey(original), timestamp, original, "text/html", "200", "-", "-"})
+		}
+	}
+	f.mutex.Unlock()
+
+	sort.Slice(rows[1:], func(i, j int) bool { return rows[i+1][1] < rows[j+1][1] })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// fakeJPEG encodes a small solid JPEG so downloads pass signature and decode checks
+func fakeJPEG(t *testing.T) []byte {
+	canvas := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 48; y++ {
+			canvas.Set(x, y, color.RGBA{R: 200, G: 80, B: 20, A: 255})
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, canvas, nil); err != nil {
+		t.Fatalf("encoding fake JPEG: %v", err)
+	}
+	return encoded.Bytes()
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	nethttp "net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/tls-client/profiles"
+)
+
+// Fetcher is the HTTP client every timemap, page and image request goes through
+// tls-client satisfies it directly, the other implementations adapt or wrap one
+type Fetcher interface {
+	Do(req *http.Request) (*http.Response, error)
+	GetProxy() string
+	SetProxy(proxyURL string) error
+}
+
+// Transports selectable with -transport
+var Transports = []string{"tls", "net"}
+
+// ValidateTransport checks the configured transport and tls-client profile exist
+func ValidateTransport() error {
+	return checkTransport(Transport, ClientProfile)
+}
+
+func checkTransport(transport string, profile string) error {
+	switch transport {
+	case "tls":
+		if _, ok := profiles.MappedTLSClients[profile]; !ok {
+			return fmt.Errorf("unknown client profile %q, available: %s", profile, strings.Join(sortedKeys(profiles.MappedTLSClients), ", "))
+		}
+	case "net":
+	default:
+		return fmt.Errorf("unknown transport %q, available: %s", transport, strings.Join(Transports, ", "))
+	}
+	return nil
+}
+
+// newFetcher builds the configured transport for a proxy, wrapped in the response cache and recorder when enabled
+func newFetcher(proxy string) (Fetcher, error) {
+	var fetcher Fetcher
+	var err error
+
+	switch Transport {
+	case "net":
+		fetcher, err = NewNetFetcher(proxy)
+	default:
+		fetcher, err = newTLSClient(proxy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if HTTPCacheDir != "" {
+		fetcher = &CachingFetcher{Fetcher: fetcher, Dir: HTTPCacheDir}
+	}
+	if RunCassette != nil && RecordDir != "" {
+		fetcher = &RecordingFetcher{Fetcher: fetcher, Cassette: RunCassette}
+	}
+	return fetcher, nil
+}
+
+// NetFetcher adapts the standard library client, for when the tls-client fingerprint is not wanted
+type NetFetcher struct {
+	client *nethttp.Client
+	proxy  string
+}
+
+func NewNetFetcher(proxy string) (*NetFetcher, error) {
+	fetcher := &NetFetcher{client: &nethttp.Client{Timeout: 30 * time.Second}}
+	return fetcher, fetcher.SetProxy(proxy)
+}
+
+func (f *NetFetcher) SetProxy(proxyURL string) error {
+	// Mirror the tls-client transport options so the two transports behave alike
+	transport := &nethttp.Transport{
+		DisableKeepAlives:      true,
+		DisableCompression:     true,
+		MaxResponseHeaderBytes: 1 << 26,
+	}
+	if proxyURL != "" {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil {
+			return err
+		}
+		transport.Proxy = nethttp.ProxyURL(parsed)
+	}
+
+	f.client.Transport = transport
+	f.proxy = proxyURL
+	return nil
+}
+
+func (f *NetFetcher) GetProxy() string { return f.proxy }
+
+func (f *NetFetcher) Do(req *http.Request) (*http.Response, error) {
+	netReq, err := nethttp.NewRequestWithContext(req.Context(), req.Method, req.URL.String(), req.Body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range requestHeaders {
+		if key != http.HeaderOrderKey && key != http.PHeaderOrderKey {
+			netReq.Header[key] = values
+		}
+	}
+	for key, values := range req.Header {
+		if key != http.HeaderOrderKey && key != http.PHeaderOrderKey {
+			netReq.Header[key] = values
+		}
+	}
+
+	netResp, err := f.client.Do(netReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// The request is pointed at the final URL after redirects, as tls-client does
+	served := req.Clone(req.Context())
+	served.URL = netResp.Request.URL
+
+	return &http.Response{
+		Status:        netResp.Status,
+		StatusCode:    netResp.StatusCode,
+		Proto:         netResp.Proto,
+		ProtoMajor:    netResp.ProtoMajor,
+		ProtoMinor:    netResp.ProtoMinor,
+		Header:        http.Header(netResp.Header),
+		Body:          netResp.Body,
+		ContentLength: netResp.ContentLength,
+		Request:       served,
+	}, nil
+}
+
+// Header recording the final URL of a stored response so redirect checks still work when it is served again
+const storedURLHeader = "X-Wayback-Scraper-Url"
+
+// requestKey identifies a request by its method and URL when storing its response
+func requestKey(req *http.Request) string {
+	digest := sha1.Sum([]byte(req.Method + " " + req.URL.String()))
+	return hex.EncodeToString(digest[:])
+}
+
+// StoreResponse writes resp to path in HTTP wire format and returns the stored copy in its place
+// The body is spooled to a temporary file rather than memory, as the wire format needs its length up front
+func StoreResponse(path string, resp *http.Response) (*http.Response, error) {
+	defer resp.Body.Close()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	spool, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".body.*"+PartialSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	stored := *resp
+	stored.Header = resp.Header.Clone()
+	stored.TransferEncoding = nil
+	stored.ContentLength = size
+	stored.Body = io.NopCloser(spool)
+	if resp.Request != nil && resp.Request.URL != nil {
+		stored.Header.Set(storedURLHeader, resp.Request.URL.String())
+	}
+
+	wire, wireWriter := io.Pipe()
+	go func() { wireWriter.CloseWithError(stored.Write(wireWriter)) }()
+	_, err = WriteFileAtomic(wire, path, -1)
+	wire.Close() // Stops the writer if the file could not be written
+	if err != nil {
+		return nil, err
+	}
+	return LoadResponse(path, resp.Request)
+}
+
+// storedBody is the body of a stored response, closing the file it is read from
+type storedBody struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (b storedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.file.Close()
+}
+
+// LoadResponse reads a response stored at path as the answer to req, streaming its body from the file
+func LoadResponse(path string, req *http.Request) (*http.Response, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(file), req)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	resp.Body = storedBody{ReadCloser: resp.Body, file: file}
+
+	if storedURL := resp.Header.Get(storedURLHeader); storedURL != "" {
+		if servedURL, err := url.Parse(storedURL); err == nil {
+			served := req.Clone(req.Context())
+			served.URL = servedURL
+			resp.Request = served
+		}
+		resp.Header.Del(storedURLHeader)
+	}
+	return resp, nil
+}
+
+// Matches the path of a replayed capture, i.e. /web/20200126021126if_/https://pbs.twimg.com/media/A.jpg
+// The timemap and CDX API answer differently as new captures are made, so they never match
+var cacheableRegex = regexp.MustCompile(`^/web/[0-9]{14}[a-z]*_/`)
+
+// CachingFetcher serves repeat GET requests for captures from responses stored on disk
+// Only 200s and Wayback's 404s are stored, as both are stable answers from the archive
+type CachingFetcher struct {
+	Fetcher
+	Dir string
+}
+
+func (f *CachingFetcher) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || !cacheableRegex.MatchString(req.URL.Path) {
+		return f.Fetcher.Do(req)
+	}
+
+	path := filepath.Join(f.Dir, requestKey(req)+".http")
+	if resp, err := LoadResponse(path, req); err == nil {
+		return resp, nil
+	} else if !os.IsNotExist(err) {
+		slog.Warn("Ignoring unreadable cached response", "url", req.URL.String(), "path", path, "error", err)
+	}
+
+	resp, err := f.Fetcher.Do(req)
+	if err != nil || (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound) {
+		return resp, err
+	}
+
+	stored, err := StoreResponse(path, resp)
+	if err != nil {
+		return nil, fmt.Errorf("caching response: %w", err)
+	}
+	return stored, nil
+}
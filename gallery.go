@@ -0,0 +1,160 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GalleryItem is a single downloaded file and whatever provenance is known about it
+type GalleryItem struct {
+	File       string // Path relative to the user directory
+	Thumb      string // Thumbnail relative to the user directory, the file itself if there is none
+	Resource   string
+	Captured   time.Time
+	WaybackURL string // Archived copy of the image itself
+	PageURL    string // Archived page (usually the tweet) the image was first seen on
+}
+
+// GalleryGroup is every item captured in the same month
+type GalleryGroup struct {
+	Month string
+	Items []GalleryItem
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Username}} - Wayback Gallery</title>
+<style>
+body { font-family: sans-serif; margin: 2em; background: #111; color: #eee; }
+a { color: #8cf; }
+.grid { display: flex; flex-wrap: wrap; gap: 8px; }
+.item { width: 160px; font-size: 0.75em; }
+.item img { width: 160px; height: 160px; object-fit: cover; cursor: zoom-in; display: block; }
+#lightbox { display: none; position: fixed; inset: 0; background: rgba(0,0,0,0.9); align-items: center; justify-content: center; cursor: zoom-out; }
+#lightbox img { max-width: 95vw; max-height: 95vh; }
+</style>
+</head>
+<body>
+<h1>{{.Username}} - {{.Total}} files</h1>
+{{range .Groups}}<h2>{{.Month}}</h2>
+<div class="grid">
+{{range .Items}}<div class="item">
+<img src="{{.Thumb}}" data-full="{{.File}}" alt="{{.File}}" loading="lazy" onclick="openLightbox(this.dataset.full)">
+{{.Resource}}{{if not .Captured.IsZero}} - {{.Captured.Format "2006-01-02"}}{{end}}<br>
+{{if .WaybackURL}}<a href="{{.WaybackURL}}">wayback</a>{{end}}
+{{if .PageURL}}<a href="{{.PageURL}}">page</a>{{end}}
+</div>
+{{end}}</div>
+{{end}}<div id="lightbox" onclick="this.style.display='none'"><img id="lightbox-image" alt=""></div>
+<script>
+function openLightbox(src) {
+  document.getElementById('lightbox-image').src = src;
+  document.getElementById('lightbox').style.display = 'flex';
+}
+document.addEventListener('keydown', function (event) {
+  if (event.key === 'Escape') { document.getElementById('lightbox').style.display = 'none'; }
+});
+</script>
+</body>
+</html>
+`))
+
+// BuildGallery lists every downloaded file grouped by capture month, newest first
+func BuildGallery() []GalleryGroup {
+	// Map local filenames back to the image URL they were downloaded from
+	ProvenanceMutex.Lock()
+	provenanceByFile := make(map[string]*Provenance)
+	for imageURL, provenance := range ImageProvenance {
+		provenanceByFile[ImageFilename(imageURL)] = provenance
+	}
+	ProvenanceMutex.Unlock()
+
+	groups := make(map[string][]GalleryItem)
+	for _, resource := range Resources {
+		paths, err := filepath.Glob(filepath.Join(UsernameLocation, resource, "*"))
+		if err != nil {
+			continue
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() || strings.HasSuffix(path, PartialSuffix) {
+				continue
+			}
+
+			// Downloads have their mtime set to the capture time, provenance is preferred when known
+			item := GalleryItem{
+				File:     filepath.ToSlash(filepath.Join(resource, filepath.Base(path))),
+				Resource: resource,
+				Captured: info.ModTime().UTC(),
+			}
+			item.Thumb = item.File
+			if thumbPath := ThumbnailPath(path); thumbnailCurrent(path, thumbPath) {
+				if relative, err := filepath.Rel(UsernameLocation, thumbPath); err == nil {
+					item.Thumb = filepath.ToSlash(relative)
+				}
+			}
+			if provenance, ok := provenanceByFile[filepath.Base(path)]; ok {
+				item.Captured = ParseWaybackTimestamp(provenance.FirstSeen)
+				item.WaybackURL = Snapshot{Timestamp: provenance.FirstSeen, URL: provenance.URL}.ReplayURL()
+				item.PageURL = Snapshot{Timestamp: provenance.FirstSeen, URL: provenance.PageURL}.ReplayURL()
+			}
+
+			month := "Unknown"
+			if !item.Captured.IsZero() {
+				month = item.Captured.Format("2006-01")
+			}
+			groups[month] = append(groups[month], item)
+		}
+	}
+
+	gallery := []GalleryGroup{}
+	for month, items := range groups {
+		sort.Slice(items, func(i, j int) bool { return items[i].Captured.After(items[j].Captured) })
+		gallery = append(gallery, GalleryGroup{Month: month, Items: items})
+	}
+	sort.Slice(gallery, func(i, j int) bool { return gallery[i].Month > gallery[j].Month })
+
+	return gallery
+}
+
+// createGallery writes gallery.html to the user directory, linking to the files beside it
+func createGallery() {
+	groups := BuildGallery()
+
+	total := 0
+	for _, group := range groups {
+		total += len(group.Items)
+	}
+	if total == 0 {
+		slog.Warn("No downloaded files found", "path", UsernameLocation)
+		return
+	}
+
+	path := filepath.Join(UsernameLocation, "gallery.html")
+	galleryFile, err := os.Create(path)
+	if err != nil {
+		slog.Error("Error creating gallery", "error", err)
+		return
+	}
+	defer galleryFile.Close()
+
+	err = galleryTemplate.Execute(galleryFile, map[string]interface{}{
+		"Username": TwitterUsername,
+		"Total":    total,
+		"Groups":   groups,
+	})
+	if err != nil {
+		slog.Error("Error writing gallery", "path", path, "error", err)
+		return
+	}
+
+	slog.Info("Gallery created", "files", total, "path", path)
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"sync"
+	"time"
 )
 
 var (
@@ -11,20 +16,34 @@ var (
 	ErrPageMissingContent = fmt.Errorf("404 - Page not found")
 	ErrPageRetries        = fmt.Errorf("error fetching page content after %d retries", RetryAttempts)
 	ErrImageRetries       = fmt.Errorf("failed save image after %d retries", RetryAttempts)
+	ErrPayloadEmpty       = fmt.Errorf("empty response body")
+	ErrPayloadPlaceholder = fmt.Errorf("wayback placeholder page instead of an archived capture")
+	ErrPayloadRedirected  = fmt.Errorf("redirected away from twitter media")
+	ErrPayloadNotImage    = fmt.Errorf("response is not a supported media type")
+	ErrNotInCassette      = fmt.Errorf("request was not recorded in the cassette")
+	ErrHTTPClient         = fmt.Errorf("unable to create an HTTP client")
+	ErrEmptyUsername      = fmt.Errorf("username is empty")
+	ErrInvalidUsername    = fmt.Errorf("username can only contain alphanumeric characters and underscores (1-15 characters)")
+	ErrJobNotFound        = fmt.Errorf("job not found")
+	ErrJobFinished        = fmt.Errorf("job has already finished")
 
 	// Resource variables
-	Resources = []string{"media", "profile"}
+	Resources = []string{"media", "profile", "banner"}
 
 	// Proxy variables
-	Proxies       []string
-	ProxiesActive []string
-	ProxyMutex    sync.Mutex
-	UseProxies    bool
+	ProxyFile      = filepath.Join(HomeDirectory, "proxies", "proxies.txt")
+	Proxies        []string
+	ProxiesActive  []string
+	ProxyMutex     sync.Mutex
+	ProxyRotations = 0
+	UseProxies     bool
 
 	// Page variables
-	PageUnprocessed []string
-	PageProcessed   []string
+	PageUnprocessed []Snapshot
+	PageProcessed   []Snapshot
+	PageSnapshots   []Snapshot // Every capture the timemap listed for this run
 	TotalPages      = 0
+	TimemapFailed   = false // The capture list could not be fetched, as opposed to listing no captures
 	PageMutex       sync.Mutex
 
 	// Image variables
@@ -35,25 +54,113 @@ var (
 	TotalDownloads   = 0
 	ImageMutex       sync.Mutex
 
+	// Report variables
+	RunStarted       time.Time
+	RunContext       = context.Background() // Cancelled to stop the run early
+	LastJSONReport   string                 // JSON report written by the latest run
+	PageRecords      []PageRecord
+	ImageRecords     []ImageRecord
+	FailureRecords   []FailureRecord
+	FilteredImages   = 0
+	QuarantinedFiles = 0
+	DiscoveredImages []string
+	ImageSightings   = 0 // Every image URL found on every page, before duplicates are removed
+	Latencies        = make(map[string]LatencySummary)
+	CSVReport        = false // Also write a per-image CSV report
+	DiffReport       = true  // Compare each run against the previous run's JSON report
+	WriteWARC        = false // Record every page and image fetch in a WARC file
+	WARCOutput       *WARCWriter
+	ReportMutex      sync.Mutex
+
+	// Logging variables
+	LogFormat = "text" // "text" for people or "json" for log aggregation
+	LogLevel  = "info"
+	QuietLogs = false // Only log warnings and errors
+
+	// Dashboard variables
+	ShowDashboard    = false            // Redraw live progress in place instead of scrolling log lines
+	ProgressInterval = 10 * time.Second // How often progress is logged when the dashboard cannot be drawn
+	RunDashboard     *Dashboard
+
+	// Metrics variables
+	MetricsAddr string // Address to serve Prometheus metrics on, i.e. :9090, disabled when empty
+
+	// Catalog variables
+	CatalogPath      string // SQLite catalog to record the run in, disabled when empty
+	Catalog          *sql.DB
+	CatalogAccountID int64
+	CatalogRunID     int64
+
+	// Provenance variables
+	ImageProvenance = make(map[string]*Provenance)
+	ProvenanceMutex sync.Mutex
+
 	// URL variables
 	WaybackResultsURL string
-	WaybackPrefix     = "https://web.archive.org/web/20200126021126if_/"
+	WaybackHost       = "https://web.archive.org" // Base URL of the archive, swapped for a fake server in tests
+	WaybackPrefix     = "/web/20200126021126if_/"
+
+	// Transport variables
+	Transport     = "tls"         // HTTP client, "tls" for tls-client or "net" for net/http
+	ClientProfile = "firefox_120" // tls-client browser fingerprint
+	HTTPCacheDir  string          // Serve repeat requests from responses stored here, disabled when empty
+	RecordDir     string          // Record every HTTP exchange to this cassette directory
+	ReplayDir     string          // Answer every request from this cassette directory instead of the network
+	RunCassette   *Cassette
+
+	// Server variables
+	ServeAddr   = "localhost:8080"
+	JobsPath    string  // Defaults to jobs.json in HomeDirectory
+	FatalPanics = false // Fatal panics instead of exiting, so a failed job does not stop the server
+
+	// Config variables
+	ConfigPath    string            // Defaults to wayback.yaml in HomeDirectory when it exists
+	ConfigProfile string            // Named group of settings from BuiltinProfiles or the config file
+	ConfigSources map[string]string // Setting -> where its value came from, for config show
+
+	// Hook variables
+	WebhookURLs    []string // Sent every event as a JSON POST
+	HookCommands   []string // Run through the shell with every event on stdin
+	HookEvents     []string // Events to fire hooks for, empty for all of them
+	WebhookRetries = 3
+
+	// Watch variables
+	WatchInterval  = 6 * time.Hour
+	SinceTimestamp string // Only captures newer than this are processed, set per account by watch
+
+	// Local archive variables
+	ArchivePaths []string // WARC and WACZ files or directories to read instead of the live Wayback Machine
+	LocalArchive *Archive
 
 	// Directory variables
 	HomeDirectory    = GetPWD()
 	UsernameLocation string
 	MediaDir         string
 	ProfileDir       string
+	BannerDir        string
+	CorruptDir       string
+	ThumbsDir        string
+	CacheDir         string
+	PartialSuffix    = ".part"
 
 	// Twitter variables
 	TwitterUsername string
 
 	// Regular expressions
-	MediaRegex    = regexp.MustCompile(`https://pbs.twimg.com/media/[A-Za-z0-9_.\-]+.jpg`)
-	ProfileRegex  = regexp.MustCompile(`https://pbs.twimg.com/profile_images/[0-9]+/[A-Za-z0-9_.\-]+.jpg`)
-	FilenameRegex = regexp.MustCompile(`[A-Za-z0-9_.\-]+.jpg`)
+	MediaRegex      = regexp.MustCompile(`https://pbs.twimg.com/media/[A-Za-z0-9_.\-]+.jpg`)
+	ProfileRegex    = regexp.MustCompile(`https://pbs.twimg.com/profile_images/[0-9]+/[A-Za-z0-9_.\-]+.jpg`)
+	BannerRegex     = regexp.MustCompile(`https://pbs.twimg.com/profile_banners/[0-9]+/[0-9]+(/[0-9a-z]+)?`)
+	FilenameRegex   = regexp.MustCompile(`[A-Za-z0-9_.\-]+.jpg`)
+	UsernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{1,15}$`)
 
 	// Other variables
 	MaxThreads    = 50
 	RetryAttempts = 5
+	DecodeImages  = false // Fully decode images when validating to detect truncation
+	EmbedMetadata = false // Write source URL, capture date and username into JPEGs as XMP
+	CachePages    = true  // Keep gzipped HTML of every parsed page for offline reparsing
+
+	// Thumbnail variables
+	ThumbnailSize    = 200
+	ThumbnailThreads = runtime.NumCPU()
 )
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	nethttp "net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Hook events, selectable with -hook-events
+const (
+	HookRunStart         = "run.start"
+	HookRunComplete      = "run.complete"
+	HookImageSaved       = "image.saved"
+	HookImageQuarantined = "image.quarantined" // A saved file failed validation and was moved out of the user directory
+	HookRunError         = "run.error"
+)
+
+var HookEventNames = []string{HookRunStart, HookRunComplete, HookImageSaved, HookImageQuarantined, HookRunError}
+
+// HookEvent is the JSON payload posted to webhooks and written to hook commands' stdin
+type HookEvent struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Username string    `json:"username"`
+	Data     any       `json:"data,omitempty"`
+}
+
+// RunSummary is the data of a run.complete event
+type RunSummary struct {
+	TextReport string         `json:"text_report"`
+	JSONReport string         `json:"json_report,omitempty"`
+	StartTime  time.Time      `json:"start_time"`
+	EndTime    time.Time      `json:"end_time"`
+	Counts     map[string]int `json:"counts"`
+}
+
+// Events are delivered one at a time in the order they were fired, so a webhook sees run.start first
+// A slow or dead hook must not hold up the run, so image.saved events are dropped once the queue is full and
+// a hook that gave up is skipped for hookCooldown
+var (
+	hookQueue    = make(chan HookEvent, 1024)
+	hookPending  sync.WaitGroup
+	hookWorker   sync.Once
+	hookDropped  atomic.Int64
+	hookDown     = map[string]time.Time{} // Webhook or command -> when to try it again, only used by the delivery worker
+	hookClient   = &nethttp.Client{Timeout: 10 * time.Second}
+	hookBackoff  = time.Second      // Doubled after every failed webhook attempt
+	hookTimeout  = 30 * time.Second // Total time spent delivering one event to one webhook or command
+	hookCooldown = time.Minute
+)
+
+// ValidateHooks checks the webhook URLs and event names
+func ValidateHooks() error {
+	for _, webhook := range WebhookURLs {
+		parsed, err := url.Parse(webhook)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("invalid webhook URL %q", webhook)
+		}
+	}
+	for _, event := range HookEvents {
+		if !slices.Contains(HookEventNames, event) {
+			return fmt.Errorf("unknown hook event %q, available: %s", event, strings.Join(HookEventNames, ", "))
+		}
+	}
+	return nil
+}
+
+// FireHook queues an event for every configured webhook and command
+func FireHook(event string, data any) {
+	if len(WebhookURLs) == 0 && len(HookCommands) == 0 {
+		return
+	}
+	if len(HookEvents) > 0 && !slices.Contains(HookEvents, event) {
+		return
+	}
+
+	hookWorker.Do(func() { go deliverHooks() })
+	hookPending.Add(1)
+	queued := HookEvent{Event: event, Time: time.Now(), Username: TwitterUsername, Data: data}
+	if event != HookImageSaved {
+		hookQueue <- queued
+		return
+	}
+	// image.saved is fired by the download workers, which must not wait on deliveries
+	select {
+	case hookQueue <- queued:
+	default:
+		hookPending.Done()
+		hookDropped.Add(1)
+	}
+}
+
+// FlushHooks waits until every fired event has been delivered or given up on
+func FlushHooks() {
+	hookPending.Wait()
+	if dropped := hookDropped.Swap(0); dropped > 0 {
+		slog.Warn("Dropped hook events, deliveries were falling behind or failing", "dropped", dropped)
+	}
+}
+
+// hookAvailable reports whether a webhook or command should be tried, counting the event as dropped if not
+func hookAvailable(target string) bool {
+	if time.Now().Before(hookDown[target]) {
+		hookDropped.Add(1)
+		return false
+	}
+	return true
+}
+
+func deliverHooks() {
+	for event := range hookQueue {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			slog.Error("Error encoding hook event", "event", event.Event, "error", err)
+			hookPending.Done()
+			continue
+		}
+
+		for _, webhook := range WebhookURLs {
+			if hookAvailable(webhook) && !postWebhook(webhook, event.Event, payload) {
+				hookDown[webhook] = time.Now().Add(hookCooldown)
+			}
+		}
+		for _, command := range HookCommands {
+			if hookAvailable(command) && !runHookCommand(command, event, payload) {
+				hookDown[command] = time.Now().Add(hookCooldown)
+			}
+		}
+		hookPending.Done()
+	}
+}
+
+// postWebhook delivers an event, retrying transport errors, 429s and 5xx responses for up to hookTimeout
+// It returns false if the webhook could not be reached, so it can be skipped for a while
+func postWebhook(webhook string, event string, payload []byte) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	backoff := hookBackoff
+	for i := 0; i <= WebhookRetries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				slog.Error("Giving up delivering webhook", "url", webhook, "event", event, "attempts", i, "timeout", hookTimeout)
+				return false
+			}
+			backoff *= 2
+		}
+
+		req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, webhook, bytes.NewReader(payload))
+		if err != nil {
+			slog.Error("Error building webhook request", "url", webhook, "event", event, "error", err)
+			return true
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Wayback-Scraper-Event", event)
+
+		resp, err := hookClient.Do(req)
+		if err != nil {
+			slog.Warn("Retrying - Error delivering webhook", "url", webhook, "event", event, "attempt", i+1, "error", err)
+			continue
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode < 300:
+			slog.Debug("Delivered webhook", "url", webhook, "event", event, "status", resp.StatusCode)
+			return true
+		case resp.StatusCode == nethttp.StatusTooManyRequests || resp.StatusCode >= 500:
+			slog.Warn("Retrying - Webhook returned an error", "url", webhook, "event", event, "attempt", i+1, "status", resp.StatusCode)
+		default:
+			slog.Error("Webhook rejected event", "url", webhook, "event", event, "status", resp.StatusCode)
+			return true
+		}
+	}
+	slog.Error("Giving up delivering webhook", "url", webhook, "event", event, "attempts", WebhookRetries+1)
+	return false
+}
+
+// runHookCommand runs a command through the shell with the event on stdin and in WAYBACK_* environment variables
+// The command is killed after hookTimeout, returning false so it can be skipped for a while
+func runHookCommand(command string, event HookEvent, payload []byte) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), "WAYBACK_EVENT="+event.Event, "WAYBACK_USERNAME="+event.Username)
+	// Output is read until every process holding the pipe exits, so do not wait on children of a killed shell
+	cmd.WaitDelay = time.Second
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			slog.Error("Hook command timed out", "command", command, "event", event.Event, "timeout", hookTimeout)
+			return false
+		}
+		slog.Error("Hook command failed", "command", command, "event", event.Event, "error", err, "output", strings.TrimSpace(string(output)))
+	}
+	return true
+}
+
+// FatalError is what Fatal panics with when FatalPanics is set, after run.error has been fired
+type FatalError struct{ Message string }
+
+func (e FatalError) Error() string { return e.Message }
+
+// Fatal logs an error that ends the run, fires run.error, waits for hooks to be delivered and exits
+// With FatalPanics set it panics with a FatalError instead, ending only the current job
+func Fatal(message string, args ...any) {
+	slog.Error(message, args...)
+
+	data := map[string]any{"message": message}
+	for i := 0; i+1 < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			continue
+		}
+		if err, ok := args[i+1].(error); ok {
+			data[key] = err.Error()
+		} else {
+			data[key] = args[i+1]
+		}
+	}
+	FireHook(HookRunError, data)
+	FlushHooks()
+	if FatalPanics {
+		if err, ok := data["error"]; ok {
+			message = fmt.Sprintf("%s: %v", message, err)
+		}
+		panic(FatalError{Message: message})
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useHooks points the hooks at the given webhook and commands for the rest of the test
+func useHooks(t *testing.T, webhooks []string, commands []string, events []string) {
+	previousWebhooks, previousCommands, previousEvents, previousBackoff := WebhookURLs, HookCommands, HookEvents, hookBackoff
+	previousTimeout := hookTimeout
+	t.Cleanup(func() {
+		WebhookURLs, HookCommands, HookEvents, hookBackoff = previousWebhooks, previousCommands, previousEvents, previousBackoff
+		hookTimeout = previousTimeout
+	})
+	WebhookURLs, HookCommands, HookEvents, hookBackoff = webhooks, commands, events, time.Millisecond
+}
+
+func TestHooksFireDuringScrape(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+
+	const media, broken = "https://pbs.twimg.com/media/GOOD.jpg", "https://pbs.twimg.com/media/BROKEN.jpg"
+	jpegData := fakeJPEG(t)
+	fake.AddPage("20150101000000", "https://twitter.com/jack", `<html><body><img src="`+media+`"><img src="`+broken+`"></body></html>`)
+	fake.AddResponse(media, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData, Archived: true})
+	fake.AddResponse(broken, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData[:len(jpegData)/2], Archived: true})
+
+	var mutex sync.Mutex
+	var events []HookEvent
+	failures := 1
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// The first delivery fails, so run.start only arrives through a retry
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event HookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		if r.Header.Get("X-Wayback-Scraper-Event") != event.Event {
+			t.Errorf("event header %q does not match payload %q", r.Header.Get("X-Wayback-Scraper-Event"), event.Event)
+		}
+		events = append(events, event)
+	}))
+	defer webhook.Close()
+	useHooks(t, []string{webhook.URL}, nil, nil)
+
+	inputUsername("jack")
+	Scrape()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	var names []string
+	for _, event := range events {
+		names = append(names, event.Event)
+		if event.Username != "jack" {
+			t.Errorf("%s event username = %q, want jack", event.Event, event.Username)
+		}
+	}
+	// The truncated image is saved, then quarantined when the run validates its files
+	want := "run.start image.saved image.saved image.quarantined run.complete"
+	if got := strings.Join(names, " "); got != want {
+		t.Fatalf("webhook received %q, want %s", got, want)
+	}
+
+	quarantined, _ := events[3].Data.(map[string]any)
+	if !strings.HasSuffix(fmt.Sprint(quarantined["path"]), "BROKEN.jpg") {
+		t.Errorf("image.quarantined data = %v, want the truncated image", quarantined)
+	}
+	summary, _ := events[4].Data.(map[string]any)
+	counts, _ := summary["counts"].(map[string]any)
+	if counts["images_downloaded"] != float64(2) || summary["json_report"] == "" {
+		t.Errorf("run.complete data = %v, want the report summary with 2 images downloaded", summary)
+	}
+}
+
+func TestHookCommandReceivesFilteredEvents(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook command test uses sh")
+	}
+
+	output := filepath.Join(t.TempDir(), "events.txt")
+	useHooks(t, nil, []string{`printf '%s ' "$WAYBACK_EVENT" >> ` + output}, []string{HookRunError})
+
+	FireHook(HookRunStart, nil)
+	FireHook(HookRunError, map[string]any{"message": "boom"})
+	FlushHooks()
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "run.error " {
+		t.Errorf("hook command saw %q, want only run.error", data)
+	}
+}
+
+func TestSlowHooksDoNotHoldUpTheRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook command test uses sh")
+	}
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the client gives up, which the server only notices once the body is read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer webhook.Close()
+	useHooks(t, []string{webhook.URL}, []string{"sleep 10"}, nil)
+	hookTimeout = 100 * time.Millisecond
+
+	started := time.Now()
+	for range 2000 {
+		FireHook(HookImageSaved, nil)
+	}
+	FireHook(HookRunComplete, nil)
+	FlushHooks()
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("firing and flushing events took %s with a hanging webhook and command", elapsed)
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	useHooks(t, []string{"ftp://example.com"}, nil, nil)
+	if ValidateHooks() == nil {
+		t.Error("accepted a non-HTTP webhook URL")
+	}
+
+	useHooks(t, []string{"https://example.com/hook"}, nil, []string{"run.finish"})
+	if ValidateHooks() == nil {
+		t.Error("accepted an unknown hook event")
+	}
+}
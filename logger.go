@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gookit/color"
+)
+
+// Log levels selectable with -log-level
+var LogLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// ConfigureLogging installs the default slog logger from LogFormat, LogLevel and QuietLogs
+func ConfigureLogging() error {
+	level, ok := LogLevels[strings.ToLower(LogLevel)]
+	if !ok {
+		return fmt.Errorf("unknown log level %q, available: %s", LogLevel, strings.Join(sortedKeys(LogLevels), ", "))
+	}
+	if QuietLogs && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+
+	var handler slog.Handler
+	switch LogFormat {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	case "text":
+		// While the dashboard is drawn it shows log lines in its recent events pane
+		var writer io.Writer = os.Stdout
+		if RunDashboard != nil {
+			writer = RunDashboard
+		}
+		handler = newConsoleHandler(writer, level, useColor(os.Stdout))
+	default:
+		return fmt.Errorf("unknown log format %q, available: text, json", LogFormat)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// useColor reports whether output to file should be coloured, honouring NO_COLOR and plain pipes and files
+func useColor(file *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// consoleHandler writes one line per record for people reading a terminal
+// The message is coloured by level and followed by its fields as key=value pairs
+type consoleHandler struct {
+	writer io.Writer
+	level  slog.Level
+	color  bool
+	mutex  *sync.Mutex
+	fields string // Fields added with WithAttrs, already formatted
+	group  string // Prefix for field keys added with WithGroup
+}
+
+func newConsoleHandler(writer io.Writer, level slog.Level, colored bool) *consoleHandler {
+	return &consoleHandler{writer: writer, level: level, color: colored, mutex: &sync.Mutex{}}
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *consoleHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := h.fields
+	record.Attrs(func(attr slog.Attr) bool {
+		fields += formatField(h.group, attr)
+		return true
+	})
+
+	levelStyle, messageStyle := color.Gray, color.Normal
+	switch {
+	case record.Level >= slog.LevelError:
+		levelStyle, messageStyle = color.Red, color.Red
+	case record.Level >= slog.LevelWarn:
+		levelStyle, messageStyle = color.Yellow, color.Yellow
+	case record.Level >= slog.LevelInfo:
+		levelStyle = color.Green
+	default:
+		messageStyle = color.Gray
+	}
+
+	line := fmt.Sprintf("%s %-5s %s", record.Time.Format(time.TimeOnly), record.Level, record.Message)
+	if h.color {
+		line = fmt.Sprintf("%s %s %s", color.Gray.Sprint(record.Time.Format(time.TimeOnly)), levelStyle.Sprintf("%-5s", record.Level), messageStyle.Sprint(record.Message))
+		fields = color.Gray.Sprint(fields)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err := fmt.Fprintln(h.writer, line+fields)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	for _, attr := range attrs {
+		handler.fields += formatField(h.group, attr)
+	}
+	return &handler
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	handler := *h
+	handler.group += name + "."
+	return &handler
+}
+
+// formatField renders an attribute as " key=value", quoting values with spaces
+func formatField(group string, attr slog.Attr) string {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return ""
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		prefix := group
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		fields := ""
+		for _, member := range attr.Value.Group() {
+			fields += formatField(prefix, member)
+		}
+		return fields
+	}
+
+	value := attr.Value.String()
+	if attr.Value.Kind() == slog.KindDuration {
+		value = attr.Value.Duration().Round(time.Millisecond).String()
+	}
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = fmt.Sprintf("%q", value)
+	}
+	return fmt.Sprintf(" %s%s=%s", group, attr.Key, value)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsoleHandlerFormatsFields(t *testing.T) {
+	var output bytes.Buffer
+	logger := slog.New(newConsoleHandler(&output, slog.LevelInfo, false)).With("stage", "images")
+
+	logger.Debug("Hidden below the level")
+	logger.Warn("Retrying - Image request failed", "url", "https://pbs.twimg.com/media/A.jpg", "attempt", 2, "status", 429,
+		"duration", 1234567*time.Microsecond, "error", "connection reset by peer")
+
+	line := output.String()
+	if strings.Contains(line, "Hidden") {
+		t.Errorf("debug record logged at info level: %q", line)
+	}
+	for _, want := range []string{
+		"WARN  Retrying - Image request failed",
+		" stage=images url=https://pbs.twimg.com/media/A.jpg attempt=2 status=429 duration=1.235s",
+		` error="connection reset by peer"`,
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line %q is missing %q", line, want)
+		}
+	}
+	if strings.Contains(line, "\x1b[") {
+		t.Errorf("uncoloured handler wrote escape codes: %q", line)
+	}
+}
+
+func TestDrawTitleSkipsNonTerminal(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousStdout, previousFormat, previousQuiet := os.Stdout, LogFormat, QuietLogs
+	t.Cleanup(func() { os.Stdout, LogFormat, QuietLogs = previousStdout, previousFormat, previousQuiet })
+	os.Stdout, LogFormat, QuietLogs = writer, "text", false
+
+	DrawTitle()
+	writer.Close()
+	os.Stdout = previousStdout
+	output, _ := io.ReadAll(reader)
+	if len(output) != 0 {
+		t.Errorf("drew the title to a pipe: %q", output)
+	}
+}
+
+func TestLogFlagsApplyToCommands(t *testing.T) {
+	useConfigFile(t, "")
+	previousFormat := LogFormat
+	t.Cleanup(func() { LogFormat = previousFormat })
+	t.Setenv("WAYBACK_LOG_LEVEL", "error")
+
+	flags := flag.NewFlagSet("timeline", flag.ContinueOnError)
+	logFlags(flags)
+	if err := flags.Parse([]string{"-log-format", "json", "-quiet", "jack"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(flags); err != nil {
+		t.Fatal(err)
+	}
+	if LogFormat != "json" || !QuietLogs || LogLevel != "error" {
+		t.Errorf("format = %q, quiet = %v, level = %q, want json, true and error from the flags and environment", LogFormat, QuietLogs, LogLevel)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+var (
+	// Matches the capture timestamp in a Wayback URL, i.e. /web/20200126021126im_/
+	captureTimestampRegex = regexp.MustCompile(`/web/([0-9]{14})`)
+
+	xmpNamespace = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+// CaptureTime works out when a downloaded file was archived
+// Twitter's own Last-Modified (preserved by Wayback as x-archive-orig-last-modified) is preferred,
+// then the Memento-Datetime of the capture, then the timestamp in the final Wayback URL
+func CaptureTime(resp *http.Response) time.Time {
+	for _, header := range []string{"x-archive-orig-last-modified", "Memento-Datetime"} {
+		if value := resp.Header.Get(header); value != "" {
+			if parsed, err := http.ParseTime(value); err == nil {
+				return parsed
+			}
+		}
+	}
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		if match := captureTimestampRegex.FindStringSubmatch(resp.Request.URL.String()); match != nil {
+			return ParseWaybackTimestamp(match[1])
+		}
+	}
+	return time.Time{}
+}
+
+// ApplyArchiveMetadata sets the file's mtime to its capture time and, if EmbedMetadata is set, writes XMP into JPEGs
+// sourceURL is the original Twitter URL, not the Wayback replay URL the file was fetched from
+func ApplyArchiveMetadata(path string, sourceURL string, resp *http.Response) {
+	captured := CaptureTime(resp)
+	if captured.IsZero() {
+		return
+	}
+
+	if EmbedMetadata {
+		if err := embedXMP(path, sourceURL, captured); err != nil {
+			slog.Error("Error embedding metadata", "path", path, "error", err)
+		}
+	}
+
+	if err := os.Chtimes(path, captured, captured); err != nil {
+		slog.Error("Error setting capture time", "path", path, "error", err)
+	}
+}
+
+func xmlEscape(value string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
+
+func buildXMPPacket(sourceURL string, captured time.Time) []byte {
+	date := captured.UTC().Format(time.RFC3339)
+	return []byte(fmt.Sprintf(`<?xpacket begin="`+"\ufeff"+`" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/">
+   <dc:source>%s</dc:source>
+   <dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>
+   <xmp:CreateDate>%s</xmp:CreateDate>
+   <photoshop:DateCreated>%s</photoshop:DateCreated>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`, xmlEscape(sourceURL), xmlEscape(TwitterUsername), date, date))
+}
+
+// embedXMP inserts an APP1 XMP segment into a JPEG, after the JFIF APP0 segment if there is one
+func embedXMP(path string, sourceURL string, captured time.Time) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if DetectFormat(data) != "jpeg" {
+		return nil
+	}
+
+	payload := append(append([]byte{}, xmpNamespace...), buildXMPPacket(sourceURL, captured)...)
+	if len(payload)+2 > 0xFFFF {
+		return fmt.Errorf("XMP packet too large")
+	}
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	insertAt := 2 // Directly after the SOI marker
+	if len(data) > 6 && data[2] == 0xFF && data[3] == 0xE0 {
+		insertAt = 4 + int(binary.BigEndian.Uint16(data[4:6]))
+	}
+	if insertAt > len(data) {
+		return fmt.Errorf("malformed JPEG segment")
+	}
+
+	var output bytes.Buffer
+	output.Write(data[:insertAt])
+	output.Write(segment)
+	output.Write(data[insertAt:])
+
+	_, err = WriteFileAtomic(&output, path, int64(output.Len()))
+	return err
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log/slog"
+	nethttp "net/http"
+	"strconv"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics, exposed on /metrics when -metrics is set
+var (
+	pagesMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wayback_pages_total",
+		Help: "Snapshot pages visited, by outcome.",
+	}, []string{"status"})
+
+	imagesMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wayback_images_total",
+		Help: "Images processed, by resource and outcome.",
+	}, []string{"resource", "status"})
+
+	bytesMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "wayback_downloaded_bytes_total",
+		Help: "Bytes of images saved to disk.",
+	})
+
+	failuresMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wayback_failures_total",
+		Help: "Pages and images that failed after every retry, by stage.",
+	}, []string{"stage"})
+
+	responsesMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wayback_http_responses_total",
+		Help: "HTTP responses received, by stage and status code, or \"error\" for transport errors.",
+	}, []string{"stage", "code"})
+
+	retriesMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wayback_retries_total",
+		Help: "Request attempts after the first, by stage.",
+	}, []string{"stage"})
+
+	proxyRotationsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "wayback_proxy_rotations_total",
+		Help: "Times a client switched to another proxy.",
+	})
+
+	latencyMetric = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wayback_request_duration_seconds",
+		Help:    "Time taken by archive requests, by stage.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"stage"})
+
+	workersMetric = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wayback_active_workers",
+		Help: "Goroutines currently fetching, by stage.",
+	}, []string{"stage"})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "wayback_queue_depth",
+		Help:        "Items waiting to be processed, by queue.",
+		ConstLabels: prometheus.Labels{"queue": "pages"},
+	}, func() float64 {
+		PageMutex.Lock()
+		defer PageMutex.Unlock()
+		return float64(len(PageUnprocessed))
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "wayback_queue_depth",
+		Help:        "Items waiting to be processed, by queue.",
+		ConstLabels: prometheus.Labels{"queue": "images"},
+	}, func() float64 {
+		ImageMutex.Lock()
+		defer ImageMutex.Unlock()
+		return float64(len(ImageUnprocessed))
+	})
+)
+
+// StartMetricsServer serves the Prometheus metrics in the background if an address was given
+func StartMetricsServer() {
+	if MetricsAddr == "" {
+		return
+	}
+
+	mux := nethttp.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := nethttp.ListenAndServe(MetricsAddr, mux); err != nil {
+			slog.Error("Error serving metrics", "addr", MetricsAddr, "error", err)
+		}
+	}()
+	slog.Info("Serving metrics", "url", "http://"+MetricsAddr+"/metrics")
+}
+
+func ObservePage(record PageRecord) {
+	pagesMetric.WithLabelValues(record.Status).Inc()
+}
+
+func ObserveImage(record ImageRecord) {
+	imagesMetric.WithLabelValues(record.Resource, record.Status).Inc()
+	bytesMetric.Add(float64(record.Size))
+}
+
+func ObserveFailure(stage string) {
+	failuresMetric.WithLabelValues(stage).Inc()
+}
+
+// ObserveResponse counts the outcome of a single request attempt
+func ObserveResponse(stage string, resp *http.Response, err error) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	responsesMetric.WithLabelValues(stage, code).Inc()
+}
+
+// ObserveAttempt counts every attempt after the first as a retry
+func ObserveAttempt(stage string, attempt int) {
+	if attempt > 0 {
+		retriesMetric.WithLabelValues(stage).Inc()
+	}
+}
+
+func ObserveLatency(stage string, duration time.Duration) {
+	latencyMetric.WithLabelValues(stage).Observe(duration.Seconds())
+}
+
+func ObserveProxyRotation() {
+	proxyRotationsMetric.Inc()
+}
+
+// TrackWorker marks a worker busy in a stage until the returned func is called
+func TrackWorker(stage string) func() {
+	workersMetric.WithLabelValues(stage).Inc()
+	return func() { workersMetric.WithLabelValues(stage).Dec() }
+}
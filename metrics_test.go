@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCountScrape(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+
+	const limited = "https://pbs.twimg.com/media/LIMITED.jpg"
+	fake.AddPage("20150101000000", "https://twitter.com/jack", `<html><body><img src="`+limited+`"></body></html>`)
+	fake.AddResponse(limited, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: fakeJPEG(t), Archived: true, RateLimits: 1})
+
+	downloaded := testutil.ToFloat64(imagesMetric.WithLabelValues("media", "downloaded"))
+	rateLimited := testutil.ToFloat64(responsesMetric.WithLabelValues("images", "429"))
+	retries := testutil.ToFloat64(retriesMetric.WithLabelValues("images"))
+	rotations := testutil.ToFloat64(proxyRotationsMetric)
+
+	runTestScrape()
+
+	if delta := testutil.ToFloat64(imagesMetric.WithLabelValues("media", "downloaded")) - downloaded; delta != 1 {
+		t.Errorf("downloaded images grew by %v, want 1", delta)
+	}
+	if delta := testutil.ToFloat64(responsesMetric.WithLabelValues("images", "429")) - rateLimited; delta != 1 {
+		t.Errorf("429 responses grew by %v, want 1", delta)
+	}
+	if delta := testutil.ToFloat64(retriesMetric.WithLabelValues("images")) - retries; delta != 1 {
+		t.Errorf("image retries grew by %v, want 1", delta)
+	}
+	if delta := testutil.ToFloat64(proxyRotationsMetric) - rotations; delta != 1 {
+		t.Errorf("proxy rotations grew by %v, want 1", delta)
+	}
+
+	recorder := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	for _, name := range []string{"wayback_queue_depth{queue=\"images\"}", "wayback_request_duration_seconds_bucket", "wayback_downloaded_bytes_total"} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("/metrics is missing %s", name)
+		}
+	}
+}
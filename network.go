@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -11,7 +13,6 @@ import (
 	http "github.com/bogdanfinn/fhttp"
 	tls_client "github.com/bogdanfinn/tls-client"
 	"github.com/bogdanfinn/tls-client/profiles"
-	"github.com/gookit/color"
 )
 
 type Proxy struct {
@@ -38,10 +39,44 @@ var (
 	}
 )
 
+// newRequest builds a GET for rawURL carrying the headers every fetch sends
+// Each request gets its own copy, as clients write the header order into it and the WARC records what was sent
+func newRequest(rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(RunContext, http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = requestHeaders.Clone()
+	return req, nil
+}
+
 // GetProxyClient() returns a new HTTP client with a random proxy from the list
-func GetProxyClient() tls_client.HttpClient {
-	proxy := getProxy()
+// When a local archive or a cassette to replay has been opened, requests are served from it instead
+// It runs on worker goroutines, so giving up is returned as ErrHTTPClient for the caller to record rather than exiting
+func GetProxyClient() (Fetcher, error) {
+	if LocalArchive != nil {
+		return &ArchiveClient{archive: LocalArchive}, nil
+	}
+	if RunCassette != nil && ReplayDir != "" {
+		return &ReplayFetcher{Cassette: RunCassette}, nil
+	}
 
+	var err error
+	for i := 0; i < RetryAttempts; i++ {
+		proxy := getProxy()
+		var client Fetcher
+		if client, err = newFetcher(proxy); err == nil {
+			return client, nil
+		}
+		releaseProxy(proxy)
+		slog.Warn("Retrying - Error creating HTTP client", "transport", Transport, "proxy", redactProxy(proxy), "attempt", i+1, "error", err)
+	}
+	slog.Error("Unable to create an HTTP client", "transport", Transport, "attempts", RetryAttempts, "error", err)
+	return nil, ErrHTTPClient
+}
+
+// newTLSClient returns a tls-client using the configured browser profile
+func newTLSClient(proxy string) (tls_client.HttpClient, error) {
 	customRedirect := func(req *http.Request, via []*http.Request) error {
 		// On redirect replace the old request with a new one but with the location from the redirect to prevent TLS EOF errors
 		// Errors come from https://github.com/bogdanfinn/fhttp/blob/master/transfer.go#L205 where either proxies and or concurrency push the redirect transfer above 200ms
@@ -54,20 +89,15 @@ func GetProxyClient() tls_client.HttpClient {
 
 	options := []tls_client.HttpClientOption{
 		tls_client.WithTimeoutSeconds(30),
-		tls_client.WithClientProfile(profiles.Firefox_120),
+		tls_client.WithClientProfile(profiles.MappedTLSClients[ClientProfile]),
 		tls_client.WithProxyUrl(proxy),
 		tls_client.WithTransportOptions(&transportOptions),
-		tls_client.WithDefaultHeaders(requestHeaders),
+		// tls-client hands its default headers to requests and writes the header order into them, so each client needs its own copy
+		tls_client.WithDefaultHeaders(requestHeaders.Clone()),
 		tls_client.WithCustomRedirectFunc(customRedirect),
 	}
 
-	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
-	if err != nil {
-		color.Magenta.Printf("Retrying - Error creating HTTP client: %+v\n", err)
-		return GetProxyClient()
-	}
-
-	return client
+	return tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
 }
 
 func getProxy() string {
@@ -78,7 +108,7 @@ func getProxy() string {
 	}
 
 	for len(Proxies) == 0 {
-		color.Red.Println("No proxies available, waiting for one to become available")
+		slog.Warn("No proxies available, waiting for one to become available")
 		time.Sleep(5 * time.Second)
 	}
 
@@ -93,20 +123,38 @@ func getProxy() string {
 	return proxy
 }
 
-func rotateClientProxy(httpClient tls_client.HttpClient) {
+func rotateClientProxy(httpClient Fetcher) {
 	returnProxy(httpClient)
 
+	ProxyMutex.Lock()
+	ProxyRotations += 1
+	ProxyMutex.Unlock()
+	ObserveProxyRotation()
+
 	err := httpClient.SetProxy(getProxy())
 	if err != nil {
-		color.Red.Printf("Error rotating proxy: %+v\n", err)
+		slog.Error("Error rotating proxy", "proxy", redactProxy(httpClient.GetProxy()), "error", err)
 		return
 	}
 }
 
-func returnProxy(httpClient tls_client.HttpClient) {
+// redactProxy returns a proxy URL's host and port for logging, leaving out its credentials
+func redactProxy(proxy string) string {
+	parsed, err := url.Parse(proxy)
+	if err != nil {
+		return "invalid proxy URL"
+	}
+	return parsed.Host
+}
+
+func returnProxy(httpClient Fetcher) {
+	releaseProxy(httpClient.GetProxy())
+}
+
+// releaseProxy moves a proxy taken by getProxy back into the pool
+func releaseProxy(activeProxy string) {
 	ProxyMutex.Lock()
 
-	activeProxy := httpClient.GetProxy()
 	for i, proxy := range ProxiesActive {
 		if proxy == activeProxy {
 			ProxiesActive = append(ProxiesActive[:i], ProxiesActive[i+1:]...)
@@ -120,34 +168,34 @@ func returnProxy(httpClient tls_client.HttpClient) {
 
 func LoadProxies() {
 	if !CheckIfProxiesExist() {
-		color.Red.Println("No proxies found, continuing without...")
+		slog.Warn("No proxies found, continuing without...")
 		return
 	}
 
-	fmt.Printf("\nLoading Proxies: %s/Proxies/Proxies.txt\n", HomeDirectory)
+	slog.Info("Loading proxies", "path", ProxyFile)
 
-	proxyFile, err := os.Open(fmt.Sprintf("%s/proxies/proxies.txt", HomeDirectory))
+	proxyFile, err := os.Open(ProxyFile)
 	if err != nil {
-		color.Red.Printf("Error opening proxy file: %+v\n", err)
-		os.Exit(1)
+		Fatal("Error opening proxy file", "error", err)
 	}
 
 	defer proxyFile.Close()
 
 	scanner := bufio.NewScanner(proxyFile)
-	for scanner.Scan() {
+	for line := 1; scanner.Scan(); line++ {
 		proxyParts := strings.Split(scanner.Text(), ":")
-		proxyString := fmt.Sprintf("http://%s:%s@%s:%s", proxyParts[2], proxyParts[3], proxyParts[0], proxyParts[1])
-		Proxies = append(Proxies, proxyString)
 		if len(proxyParts) != 4 {
-			color.Yellow.Println("Invalid proxy format:", scanner.Text())
+			// The line holds a password, so only say where it is
+			slog.Warn("Invalid proxy format", "path", ProxyFile, "line", line)
 			continue
 		}
+		proxyString := fmt.Sprintf("http://%s:%s@%s:%s", proxyParts[2], proxyParts[3], proxyParts[0], proxyParts[1])
+		Proxies = append(Proxies, proxyString)
 	}
 
 	if err := scanner.Err(); err != nil {
-		color.Red.Println("Error reading proxy file:", err)
+		slog.Error("Error reading proxy file", "error", err)
 	}
 
-	fmt.Printf("Loaded %d Proxies from file\n\n", len(Proxies))
+	slog.Info("Loaded proxies from file", "proxies", len(Proxies))
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// isArchivedCapture reports whether the response carries Wayback's capture headers
+// Real captures include x-archive-src and the original response headers as x-archive-orig-*
+// Placeholder and error pages generated by the Wayback Machine itself have neither
+func isArchivedCapture(header http.Header) bool {
+	if header.Get("x-archive-src") != "" {
+		return true
+	}
+	for key := range header {
+		if strings.HasPrefix(strings.ToLower(key), "x-archive-orig-") {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectedAway reports whether redirects ended somewhere other than sourceURL, i.e. a login interstitial
+// Wayback may redirect to a neighbouring capture of the same URL, so the original embedded in the final URL is compared,
+// by host and path, with the scheme, port and www. prefix ignored
+func redirectedAway(resp *http.Response, sourceURL string) bool {
+	if resp.Request == nil || resp.Request.URL == nil {
+		return false
+	}
+
+	_, finalURL, _ := splitWaybackURL(resp.Request.URL.String())
+	final, err := url.Parse(finalURL)
+	if err != nil {
+		return true
+	}
+	source, err := url.Parse(sourceURL)
+	if err != nil {
+		return true
+	}
+	return resourceHost(final) != resourceHost(source) || strings.TrimSuffix(final.Path, "/") != strings.TrimSuffix(source.Path, "/")
+}
+
+// resourceHost returns a URL's host without its port or www. prefix
+func resourceHost(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// ClassifyPayload checks a 200 response for sourceURL before it is written to disk
+// sniff is the start of the body, up to 512 bytes, so an empty sniff means an empty body
+func ClassifyPayload(resp *http.Response, sourceURL string, sniff []byte) error {
+	if len(sniff) == 0 {
+		return ErrPayloadEmpty
+	}
+
+	if redirectedAway(resp, sourceURL) {
+		return ErrPayloadRedirected
+	}
+
+	// Wayback's own "Hrm." and error pages are HTML without any capture headers
+	lowered := bytes.ToLower(sniff)
+	isHTML := bytes.Contains(lowered, []byte("<html")) || bytes.Contains(lowered, []byte("<!doctype html"))
+	if isHTML && (!isArchivedCapture(resp.Header) || bytes.Contains(sniff, []byte("Hrm."))) {
+		return ErrPayloadPlaceholder
+	}
+
+	// A body that happens to start with image magic bytes is still not an image if the server says it is HTML or text
+	if !isMediaType(resp.Header.Get("Content-Type")) || DetectFormat(sniff) == "" {
+		return ErrPayloadNotImage
+	}
+	return nil
+}
+
+// isMediaType reports whether a Content-Type can carry Twitter media
+// A missing or generic binary type is left to the signature check
+func isMediaType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case mediaType == "", mediaType == "application/octet-stream":
+		return true
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "video/"):
+		return true
+	}
+	return false
+}
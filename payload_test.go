@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+func TestRedirectedAway(t *testing.T) {
+	const source = "https://pbs.twimg.com/media/A.jpg"
+	for final, want := range map[string]bool{
+		"https://web.archive.org/web/20200126021126if_/https://pbs.twimg.com/media/A.jpg":         false,
+		"https://web.archive.org/web/20190101000000if_/http://pbs.twimg.com:80/media/A.jpg":       false,
+		"https://web.archive.org/web/20200126021126if_/https://pbs.twimg.com/media/B.jpg":         true,
+		"https://web.archive.org/web/20200126021126if_/https://twitter.com/login?next=/media/A":   true,
+		"https://example.com/login?next=https://pbs.twimg.com/media/A.jpg":                        true,
+		"https://pbs.twimg.com.example.com/media/A.jpg":                                           true,
+		"https://web.archive.org/web/20200126021126if_/https://example.com/pbs.twimg.com/media/A": true,
+	} {
+		finalURL, err := url.Parse(final)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := &http.Response{Request: &http.Request{URL: finalURL}}
+		if got := redirectedAway(resp, source); got != want {
+			t.Errorf("redirectedAway(%s) = %v, want %v", final, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Snapshot is a single Wayback Machine capture of a Twitter page
+type Snapshot struct {
+	Timestamp string `json:"timestamp"` // Wayback capture timestamp, i.e. 20200126021126
+	URL       string `json:"url"`
+}
+
+// Provenance records where and when an image was seen in the archive
+type Provenance struct {
+	URL       string   `json:"url"`
+	FirstSeen string   `json:"first_seen"` // Earliest capture timestamp the image appeared in
+	LastSeen  string   `json:"last_seen"`  // Latest capture timestamp the image appeared in
+	PageURL   string   `json:"page_url"`   // Page the image was first seen on
+	Sightings int      `json:"sightings"`  // Distinct captures the image was seen in
+	Seen      []string `json:"seen"`       // Sorted timestamps of those captures, so a re-scrape does not count them again
+}
+
+// WaybackURL returns the raw (if_) archive URL for the snapshot
+func (s Snapshot) WaybackURL() string {
+	return fmt.Sprintf("%s/web/%sif_/%s", WaybackHost, s.Timestamp, s.URL)
+}
+
+// ReplayURL returns the archive URL for viewing the snapshot in a browser with the Wayback toolbar
+func (s Snapshot) ReplayURL() string {
+	return fmt.Sprintf("%s/web/%s/%s", WaybackHost, s.Timestamp, s.URL)
+}
+
+// Time parses the snapshot timestamp, returning the zero time if it is malformed
+func (s Snapshot) Time() time.Time {
+	return ParseWaybackTimestamp(s.Timestamp)
+}
+
+func ParseWaybackTimestamp(timestamp string) time.Time {
+	parsed, err := time.Parse("20060102150405", timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+// RecordSighting notes that imageURL was present in the given snapshot
+func RecordSighting(imageURL string, snapshot Snapshot) {
+	CatalogSighting(imageURL, snapshot)
+
+	ProvenanceMutex.Lock()
+	defer ProvenanceMutex.Unlock()
+
+	ImageSightings += 1
+
+	provenance, ok := ImageProvenance[imageURL]
+	if !ok {
+		ImageProvenance[imageURL] = &Provenance{
+			URL:       imageURL,
+			FirstSeen: snapshot.Timestamp,
+			LastSeen:  snapshot.Timestamp,
+			PageURL:   snapshot.URL,
+			Sightings: 1,
+			Seen:      []string{snapshot.Timestamp},
+		}
+		return
+	}
+
+	position, seen := slices.BinarySearch(provenance.Seen, snapshot.Timestamp)
+	if seen {
+		return
+	}
+	provenance.Seen = slices.Insert(provenance.Seen, position, snapshot.Timestamp)
+
+	// Wayback timestamps are fixed width so they compare correctly as strings
+	if snapshot.Timestamp < provenance.FirstSeen {
+		provenance.FirstSeen = snapshot.Timestamp
+		provenance.PageURL = snapshot.URL
+	}
+	if snapshot.Timestamp > provenance.LastSeen {
+		provenance.LastSeen = snapshot.Timestamp
+	}
+	provenance.Sightings += 1
+}
+
+func provenancePath() string {
+	return filepath.Join(UsernameLocation, "provenance.json")
+}
+
+// LoadProvenance reads the provenance recorded by previous runs so history carries across scrapes
+func LoadProvenance() {
+	data, err := os.ReadFile(provenancePath())
+	if err != nil {
+		return
+	}
+
+	var stored []*Provenance
+	if err := json.Unmarshal(data, &stored); err != nil {
+		slog.Error("Error decoding provenance file", "error", err)
+		return
+	}
+
+	ProvenanceMutex.Lock()
+	for _, provenance := range stored {
+		slices.Sort(provenance.Seen)
+		ImageProvenance[provenance.URL] = provenance
+	}
+	ProvenanceMutex.Unlock()
+}
+
+// SaveProvenance writes every image sighting to provenance.json in the user directory
+func SaveProvenance() {
+	ProvenanceMutex.Lock()
+	stored := make([]*Provenance, 0, len(ImageProvenance))
+	for _, provenance := range ImageProvenance {
+		stored = append(stored, provenance)
+	}
+	ProvenanceMutex.Unlock()
+
+	sort.Slice(stored, func(i, j int) bool { return stored[i].URL < stored[j].URL })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		slog.Error("Error encoding provenance", "error", err)
+		return
+	}
+
+	if err := os.WriteFile(provenancePath(), data, 0644); err != nil {
+		slog.Error("Error writing provenance file", "error", err)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestRecordSightingCountsEachCaptureOnce(t *testing.T) {
+	previous := ImageProvenance
+	t.Cleanup(func() { ImageProvenance = previous })
+	ImageProvenance = make(map[string]*Provenance)
+
+	const avatar = "https://pbs.twimg.com/profile_images/1234/avatar.jpg"
+	first := Snapshot{Timestamp: "20150101000000", URL: "https://twitter.com/jack"}
+	second := Snapshot{Timestamp: "20140101000000", URL: "https://twitter.com/jack/status/20"}
+
+	// A re-scrape or reparse sees the same captures again
+	for range 2 {
+		RecordSighting(avatar, first)
+		RecordSighting(avatar, second)
+	}
+
+	provenance := ImageProvenance[avatar]
+	if provenance.Sightings != 2 || len(provenance.Seen) != 2 {
+		t.Errorf("sightings = %d, seen = %v, want 2 distinct captures", provenance.Sightings, provenance.Seen)
+	}
+	if provenance.FirstSeen != second.Timestamp || provenance.LastSeen != first.Timestamp || provenance.PageURL != second.URL {
+		t.Errorf("provenance = %+v, want first seen on the 2014 capture and last seen on the 2015 one", provenance)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// PageRecord is the outcome of visiting a single snapshot
+type PageRecord struct {
+	Timestamp string `json:"timestamp"`
+	URL       string `json:"url"`
+	Status    string `json:"status"` // parsed or skipped
+	Reason    string `json:"reason,omitempty"`
+}
+
+// ImageRecord is the outcome of downloading a single image
+type ImageRecord struct {
+	URL      string `json:"url"`
+	Resource string `json:"resource"`
+	Status   string `json:"status"` // downloaded or skipped
+	Reason   string `json:"reason,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+}
+
+// FailureRecord is a single failed attempt, the item is requeued afterwards
+type FailureRecord struct {
+	Stage string    `json:"stage"`
+	URL   string    `json:"url"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// RunReport is the machine-readable summary of a run written next to the text report
+type RunReport struct {
+	Username   string                    `json:"username"`
+	Since      string                    `json:"since,omitempty"` // Set when only captures after this timestamp were processed, i.e. by watch
+	StartTime  time.Time                 `json:"start_time"`
+	EndTime    time.Time                 `json:"end_time"`
+	Config     map[string]any            `json:"config"`
+	Counts     map[string]int            `json:"counts"`
+	Proxies    map[string]int            `json:"proxies"`
+	Latency    map[string]LatencySummary `json:"latency"`
+	Stats      *RunStats                 `json:"stats,omitempty"`
+	Pages      []PageRecord              `json:"pages"`
+	Discovered []string                  `json:"discovered"` // Every image URL found on the parsed pages, before filtering
+	Images     []ImageRecord             `json:"images"`
+	Failures   []FailureRecord           `json:"failures"`
+}
+
+func RecordPage(snapshot Snapshot, status string, reason error) {
+	record := PageRecord{Timestamp: snapshot.Timestamp, URL: snapshot.URL, Status: status}
+	if reason != nil {
+		record.Reason = reason.Error()
+	}
+
+	ReportMutex.Lock()
+	PageRecords = append(PageRecords, record)
+	ReportMutex.Unlock()
+
+	CatalogPage(record)
+	ObservePage(record)
+}
+
+func RecordImage(record ImageRecord) {
+	ReportMutex.Lock()
+	ImageRecords = append(ImageRecords, record)
+	ReportMutex.Unlock()
+
+	CatalogImage(record)
+	ObserveImage(record)
+	if record.Status == "downloaded" {
+		FireHook(HookImageSaved, record)
+	}
+}
+
+func RecordFailure(stage string, url string, err error) {
+	ReportMutex.Lock()
+	FailureRecords = append(FailureRecords, FailureRecord{Stage: stage, URL: url, Error: err.Error(), Time: time.Now()})
+	ReportMutex.Unlock()
+
+	ObserveFailure(stage)
+}
+
+// HashFile returns the size and hex SHA-256 of a file
+func HashFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// reportPath returns a timestamped report path in the user directory that does not exist yet
+func reportPath(suffix string) string {
+	base := filepath.Join(UsernameLocation, RunStarted.Format("2006-01-02-150405"))
+	path := base + suffix
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d%s", base, i, suffix)
+	}
+}
+
+func countStatus(status string) (pages int, images int) {
+	for _, record := range PageRecords {
+		if record.Status == status {
+			pages += 1
+		}
+	}
+	for _, record := range ImageRecords {
+		if record.Status == status {
+			images += 1
+		}
+	}
+	return pages, images
+}
+
+// BuildRunReport gathers the run's configuration, counts and records
+func BuildRunReport() RunReport {
+	ReportMutex.Lock()
+	defer ReportMutex.Unlock()
+
+	pagesParsed, _ := countStatus("parsed")
+	pagesSkipped, imagesSkipped := countStatus("skipped")
+	_, imagesDownloaded := countStatus("downloaded")
+
+	ProxyMutex.Lock()
+	proxies := map[string]int{
+		"loaded":    len(Proxies) + len(ProxiesActive),
+		"rotations": ProxyRotations,
+	}
+	ProxyMutex.Unlock()
+
+	return RunReport{
+		Username:  TwitterUsername,
+		Since:     SinceTimestamp,
+		StartTime: RunStarted,
+		EndTime:   time.Now(),
+		Config: map[string]any{
+			"max_threads":    MaxThreads,
+			"retry_attempts": RetryAttempts,
+			"use_proxies":    UseProxies,
+			"resources":      Resources,
+			"wayback_host":   WaybackHost,
+			"wayback_prefix": WaybackPrefix,
+			"decode_images":  DecodeImages,
+			"embed_metadata": EmbedMetadata,
+		},
+		Counts: map[string]int{
+			"pages_found":       TotalPages,
+			"pages_parsed":      pagesParsed,
+			"pages_skipped":     pagesSkipped,
+			"images_found":      TotalImages,
+			"images_filtered":   FilteredImages,
+			"images_downloaded": imagesDownloaded,
+			"images_skipped":    imagesSkipped,
+			"files_quarantined": QuarantinedFiles,
+			"failures":          len(FailureRecords),
+			"image_sightings":   ImageSightings,
+		},
+		Latency:    Latencies,
+		Proxies:    proxies,
+		Pages:      PageRecords,
+		Discovered: DiscoveredImages,
+		Images:     ImageRecords,
+		Failures:   FailureRecords,
+	}
+}
+
+func createReport() {
+	header := fmt.Sprintf(`=== Wayback Report - %s - %s`, TwitterUsername, GetCurrentDate())
+	totalProcessed := fmt.Sprintf("Pages Parsed: %d | Images Proccesed: %d | Downloaded Images: %d", TotalPages, TotalImages, TotalDownloads)
+	pageString := ""
+	for _, snapshot := range PageProcessed {
+		pageString += fmt.Sprintf("%s\n", snapshot.WaybackURL())
+	}
+	imageString := ""
+	for _, link := range ImageProcessed {
+		imageString += fmt.Sprintf("%s\n", link)
+	}
+
+	report := fmt.Sprintf("%s\n%s\n%s\n%s\n", header, totalProcessed, pageString, imageString)
+
+	path := reportPath("-report.txt")
+	reportFile, err := os.Create(path)
+	if err != nil {
+		slog.Error("Error creating report file", "path", path, "error", err)
+		return
+	}
+	defer reportFile.Close()
+
+	_, err = reportFile.WriteString(report)
+	if err != nil {
+		slog.Error("Error writing to report file", "path", path, "error", err)
+		return
+	}
+
+	slog.Info("Report created", "path", path)
+
+	jsonPath := createJSONReport()
+	ReportMutex.Lock()
+	LastJSONReport = jsonPath
+	ReportMutex.Unlock()
+
+	summary := BuildRunReport()
+	FireHook(HookRunComplete, RunSummary{TextReport: path, JSONReport: jsonPath, StartTime: summary.StartTime, EndTime: summary.EndTime, Counts: summary.Counts})
+	if CSVReport {
+		createCSVReport()
+	}
+	if DiffReport && jsonPath != "" {
+		if paths := RunReportPaths(); len(paths) > 1 {
+			createDiffReport(previousReportPath(paths, jsonPath), jsonPath)
+		}
+	}
+}
+
+// previousReportPath returns the report preceding current in paths
+func previousReportPath(paths []string, current string) string {
+	previous := ""
+	for _, path := range paths {
+		if path == current {
+			break
+		}
+		previous = path
+	}
+	return previous
+}
+
+// createJSONReport writes the JSON report and returns its path, or "" if it could not be written
+func createJSONReport() string {
+	report := BuildRunReport()
+	stats := BuildStats(report)
+	report.Stats = &stats
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		slog.Error("Error encoding JSON report", "error", err)
+		return ""
+	}
+
+	path := reportPath("-report.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		slog.Error("Error writing JSON report", "path", path, "error", err)
+		return ""
+	}
+
+	slog.Info("JSON report created", "path", path)
+	return path
+}
+
+// createCSVReport writes one row per image, the most common thing to open in a spreadsheet
+func createCSVReport() {
+	path := reportPath("-images.csv")
+	csvFile, err := os.Create(path)
+	if err != nil {
+		slog.Error("Error creating CSV report", "path", path, "error", err)
+		return
+	}
+	defer csvFile.Close()
+
+	writer := csv.NewWriter(csvFile)
+	writer.Write([]string{"url", "resource", "status", "reason", "path", "size", "sha256"})
+
+	ReportMutex.Lock()
+	for _, record := range ImageRecords {
+		writer.Write([]string{record.URL, record.Resource, record.Status, record.Reason, record.Path, strconv.FormatInt(record.Size, 10), record.SHA256})
+	}
+	ReportMutex.Unlock()
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Error writing CSV report", "path", path, "error", err)
+		return
+	}
+
+	slog.Info("CSV report created", "path", path)
+}
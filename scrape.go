@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Scrape runs the whole pipeline for TwitterUsername
+// Proxies and local archives are loaded once per process by the caller, everything else is per run
+func Scrape() {
+	RunStarted = time.Now()
+	CreateDirectories()                            // Create necessary directories for storing images
+	FireHook(HookRunStart, CurrentScrapeOptions()) // Tell webhooks and hook commands the run began
+	LoadProvenance()                               // Load image sightings recorded by previous runs
+	OpenRunWARC()                                  // Start the WARC file if WARC output is enabled
+	StartCatalogRun()                              // Record the run in the SQLite catalog if enabled
+	StartDashboard()                               // Draw live progress if enabled
+	OpenRunCassette()                              // Record or replay every HTTP exchange if enabled
+	CreateStoredImageMap()                         // Create an in-memory map of stored images
+	fetchWaybackPages()                            // Fetch Wayback Machine cached pages
+	if TotalPages == 0 {
+		// Finding nothing new is the expected outcome of most watch checks, failing to look is not
+		if TimemapFailed && !Cancelled() {
+			FireHook(HookRunError, map[string]any{"message": "Error fetching the list of Wayback Machine cached pages"})
+		} else if SinceTimestamp == "" && !Cancelled() {
+			FireHook(HookRunError, map[string]any{"message": "Found no cached Wayback Machine pages"})
+		}
+		StopDashboard()
+		CloseRunWARC()
+		FinishCatalogRun()
+		FlushHooks()
+		return
+	}
+	parseImages()       // Parse images from the cached pages
+	RemoveCommonItems() // Remove previously downloaded images from the unprocessedImages list
+	downloadImages()    // Download images from the Wayback Machine cache
+	CloseRunWARC()      // Finish the WARC file
+	purgeCorrupted()    // Quarantine any corrupted files
+	createThumbnails()  // Generate thumbnails for the downloaded images
+	StopDashboard()     // Return to plain log output for the summary
+	SaveProvenance()    // Save when and where each image was seen in the archive
+	createReport()      // Create a report of the downloaded images
+	printRunStats()     // Print the per-year and per-resource breakdown
+	FinishCatalogRun()  // Store the run totals in the catalog
+	FlushHooks()        // Deliver outstanding hook events before the next run or exit
+}
+
+// ResetRunState clears everything a previous run left behind so another can run in the same process
+func ResetRunState() {
+	PageMutex.Lock()
+	PageUnprocessed, PageProcessed, PageSnapshots, TotalPages, TimemapFailed = nil, nil, nil, 0, false
+	PageMutex.Unlock()
+
+	ImageMutex.Lock()
+	ImageUnprocessed, ImageProcessed, TotalImages, TotalDownloads = nil, nil, 0, 0
+	StoredImageMap = make(map[string]bool)
+	ImageMutex.Unlock()
+
+	ReportMutex.Lock()
+	PageRecords, ImageRecords, FailureRecords = nil, nil, nil
+	FilteredImages, QuarantinedFiles, ImageSightings = 0, 0, 0
+	DiscoveredImages = nil
+	Latencies = make(map[string]LatencySummary)
+	LastJSONReport = ""
+	ReportMutex.Unlock()
+
+	ProvenanceMutex.Lock()
+	ImageProvenance = make(map[string]*Provenance)
+	ProvenanceMutex.Unlock()
+
+	ProxyMutex.Lock()
+	ProxyRotations = 0
+	ProxyMutex.Unlock()
+
+	WARCOutput, RunCassette = nil, nil
+	CatalogAccountID, CatalogRunID = 0, 0
+	SinceTimestamp = ""
+	RunContext = context.Background()
+}
+
+// Cancelled reports whether the run has been asked to stop, i.e. a job cancelled through the API
+func Cancelled() bool {
+	return RunContext.Err() != nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetScrapeState points the scraper at a fake archive and clears everything a previous run left in the globals
+func resetScrapeState(t *testing.T, fake *fakeWayback) {
+	previousHost, previousHome, previousDecode, previousDiff := WaybackHost, HomeDirectory, DecodeImages, DiffReport
+	previousTransport, previousCache, previousRecord, previousReplay := Transport, HTTPCacheDir, RecordDir, ReplayDir
+	t.Cleanup(func() {
+		WaybackHost, HomeDirectory, DecodeImages, DiffReport = previousHost, previousHome, previousDecode, previousDiff
+		Transport, HTTPCacheDir, RecordDir, ReplayDir = previousTransport, previousCache, previousRecord, previousReplay
+		RunCassette = nil
+	})
+
+	WaybackHost = fake.Server.URL
+	HomeDirectory = t.TempDir()
+	DecodeImages = true
+	DiffReport = false
+
+	TwitterUsername = ""
+	RecordDir, ReplayDir = "", ""
+	ResetRunState()
+	RunStarted = time.Now()
+}
+
+// runTestScrape runs the scrape pipeline from main, minus the interactive and optional stages
+func runTestScrape() {
+	inputUsername("jack")
+	CreateDirectories()
+	OpenRunCassette()
+	CreateStoredImageMap()
+	fetchWaybackPages()
+	parseImages()
+	RemoveCommonItems()
+	downloadImages()
+	purgeCorrupted()
+	createReport()
+}
+
+func TestScrapeEndToEnd(t *testing.T) {
+	for _, transport := range Transports {
+		t.Run(transport, func(t *testing.T) {
+			fake := newFakeWayback(t)
+			resetScrapeState(t, fake)
+			Transport = transport
+			testScrapeEndToEnd(t, fake)
+		})
+	}
+}
+
+func testScrapeEndToEnd(t *testing.T, fake *fakeWayback) {
+	const (
+		goodMedia   = "https://pbs.twimg.com/media/GOOD.jpg"
+		flakyMedia  = "https://pbs.twimg.com/media/FLAKY.jpg"
+		limited     = "https://pbs.twimg.com/media/LIMITED.jpg"
+		brokenMedia = "https://pbs.twimg.com/media/BROKEN.jpg"
+		placeholder = "https://pbs.twimg.com/media/PLACEHOLDER.jpg"
+		missing     = "https://pbs.twimg.com/media/MISSING.jpg"
+		profile     = "https://pbs.twimg.com/profile_images/1234/avatar.jpg"
+	)
+
+	jpegData := fakeJPEG(t)
+	fake.AddPage("20150101000000", "https://twitter.com/jack",
+		`<html><body><img src="`+profile+`"><img src="`+goodMedia+`"><img src="`+flakyMedia+`"><img src="`+placeholder+`"></body></html>`)
+	fake.AddPage("20160101000000", "https://twitter.com/jack/status/20",
+		`<html><body><img src="`+profile+`"><img src="`+limited+`"><img src="`+brokenMedia+`"><img src="`+missing+`"></body></html>`)
+	fake.AddTimemapEntry("20170101000000", "https://twitter.com/jack/status/404")
+
+	fake.AddResponse(goodMedia, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData, Archived: true})
+	fake.AddResponse(profile, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData, Archived: true})
+	fake.AddResponse(flakyMedia, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData, Archived: true, Drops: 1})
+	fake.AddResponse(limited, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData, Archived: true, RateLimits: 1})
+	fake.AddResponse(brokenMedia, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: jpegData[:len(jpegData)/2], Archived: true})
+	fake.AddResponse(placeholder, &fakeResponse{Status: http.StatusOK, ContentType: "text/html; charset=utf-8", Body: []byte("<!DOCTYPE html><html><body><p>Hrm.</p></body></html>")})
+
+	runTestScrape()
+
+	if TotalPages != 3 {
+		t.Errorf("found %d pages, want 3", TotalPages)
+	}
+	if TotalImages != 7 {
+		t.Errorf("found %d images, want 7", TotalImages)
+	}
+
+	for _, path := range []string{
+		filepath.Join(MediaDir, "GOOD.jpg"),
+		filepath.Join(MediaDir, "FLAKY.jpg"),
+		filepath.Join(MediaDir, "LIMITED.jpg"),
+		filepath.Join(ProfileDir, "avatar.jpg"),
+		filepath.Join(CorruptDir, "media", "BROKEN.jpg"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to exist: %v", path, err)
+		}
+	}
+
+	for _, path := range []string{
+		filepath.Join(MediaDir, "BROKEN.jpg"),
+		filepath.Join(MediaDir, "PLACEHOLDER.jpg"),
+		filepath.Join(MediaDir, "MISSING.jpg"),
+	} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to be saved", path)
+		}
+	}
+
+	if hits := fake.Hits(flakyMedia); hits != 2 {
+		t.Errorf("dropped connection image requested %d times, want 2", hits)
+	}
+	if hits := fake.Hits(limited); hits != 2 {
+		t.Errorf("rate limited image requested %d times, want 2", hits)
+	}
+
+	paths := RunReportPaths()
+	if len(paths) != 1 {
+		t.Fatalf("found %d JSON reports, want 1", len(paths))
+	}
+	report, err := LoadRunReport(paths[0])
+	if err != nil {
+		t.Fatalf("loading JSON report: %v", err)
+	}
+
+	want := map[string]int{
+		"pages_found":       3,
+		"pages_parsed":      2,
+		"pages_skipped":     1,
+		"images_found":      7,
+		"images_downloaded": 5,
+		"images_skipped":    2,
+		"image_sightings":   8,
+	}
+	for key, value := range want {
+		if report.Counts[key] != value {
+			t.Errorf("report count %s = %d, want %d", key, report.Counts[key], value)
+		}
+	}
+	if QuarantinedFiles != 1 {
+		t.Errorf("quarantined %d files, want 1", QuarantinedFiles)
+	}
+}
+
+func TestScrapeSkipsStoredImages(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+
+	const stored = "https://pbs.twimg.com/media/STORED.jpg"
+	fake.AddPage("20150101000000", "https://twitter.com/jack", `<html><body><img src="`+stored+`"></body></html>`)
+	fake.AddResponse(stored, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: fakeJPEG(t), Archived: true})
+
+	inputUsername("jack")
+	CreateDirectories()
+	if err := os.WriteFile(filepath.Join(MediaDir, "STORED.jpg"), fakeJPEG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	CreateStoredImageMap()
+	fetchWaybackPages()
+	parseImages()
+	RemoveCommonItems()
+	downloadImages()
+
+	if FilteredImages != 1 {
+		t.Errorf("filtered %d images, want 1", FilteredImages)
+	}
+	if hits := fake.Hits(stored); hits != 0 {
+		t.Errorf("stored image requested %d times, want 0", hits)
+	}
+}
+
+func TestEmbeddedMetadataNamesOriginalURL(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+	previous := EmbedMetadata
+	t.Cleanup(func() { EmbedMetadata = previous })
+	EmbedMetadata = true
+
+	const media = "https://pbs.twimg.com/media/XMP.jpg"
+	fake.AddPage("20150101000000", "https://twitter.com/jack", `<html><body><img src="`+media+`"></body></html>`)
+	fake.AddResponse(media, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: fakeJPEG(t), Archived: true})
+	runTestScrape()
+
+	data, err := os.ReadFile(filepath.Join(MediaDir, "XMP.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<dc:source>"+media+"</dc:source>") {
+		t.Errorf("embedded XMP does not name %s as the source", media)
+	}
+	if strings.Contains(string(data), WaybackHost) {
+		t.Error("embedded XMP names the Wayback replay URL")
+	}
+}
+
+func TestScrapeSkipsNonImageContentType(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+
+	// JPEG bytes served as HTML or text are an error page that happens to match, not an image
+	const html, text = "https://pbs.twimg.com/media/HTML.jpg", "https://pbs.twimg.com/media/TEXT.jpg"
+	fake.AddPage("20150101000000", "https://twitter.com/jack", `<html><body><img src="`+html+`"><img src="`+text+`"></body></html>`)
+	fake.AddResponse(html, &fakeResponse{Status: http.StatusOK, ContentType: "text/html; charset=utf-8", Body: fakeJPEG(t), Archived: true})
+	fake.AddResponse(text, &fakeResponse{Status: http.StatusOK, ContentType: "text/plain", Body: fakeJPEG(t), Archived: true})
+	runTestScrape()
+
+	for _, name := range []string{"HTML.jpg", "TEXT.jpg"} {
+		if _, err := os.Stat(filepath.Join(MediaDir, name)); err == nil {
+			t.Errorf("saved %s despite its content type", name)
+		}
+	}
+	for _, record := range ImageRecords {
+		if record.Status != "skipped" || record.Reason != ErrPayloadNotImage.Error() {
+			t.Errorf("%s recorded as %s (%s), want skipped as not an image", record.URL, record.Status, record.Reason)
+		}
+	}
+	if len(ImageRecords) != 2 {
+		t.Errorf("recorded %d images, want 2", len(ImageRecords))
+	}
+}
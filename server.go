@@ -0,0 +1,480 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job statuses, a job moves from queued to running to one of the finished statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// ScrapeOptions are the per-job settings a client can choose, defaulting to the flags serve was started with
+// Paths such as -http-cache and -catalog are left to the server's flags, clients cannot choose where files are written
+type ScrapeOptions struct {
+	WARC          bool   `json:"warc"`
+	CSV           bool   `json:"csv"`
+	Decode        bool   `json:"decode"`
+	EmbedMetadata bool   `json:"embed_metadata"`
+	Cache         bool   `json:"cache"`
+	Transport     string `json:"transport"`
+	Profile       string `json:"profile"`
+}
+
+func CurrentScrapeOptions() ScrapeOptions {
+	return ScrapeOptions{
+		WARC:          WriteWARC,
+		CSV:           CSVReport,
+		Decode:        DecodeImages,
+		EmbedMetadata: EmbedMetadata,
+		Cache:         CachePages,
+		Transport:     Transport,
+		Profile:       ClientProfile,
+	}
+}
+
+func (o ScrapeOptions) apply() {
+	WriteWARC, CSVReport, DecodeImages, EmbedMetadata, CachePages = o.WARC, o.CSV, o.Decode, o.EmbedMetadata, o.Cache
+	Transport, ClientProfile = o.Transport, o.Profile
+}
+
+// Job is a scrape requested through the REST API
+type Job struct {
+	ID       string        `json:"id"`
+	Username string        `json:"username"`
+	Options  ScrapeOptions `json:"options"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Created  time.Time     `json:"created"`
+	Started  *time.Time    `json:"started,omitempty"`
+	Finished *time.Time    `json:"finished,omitempty"`
+	Report   string        `json:"report,omitempty"`   // JSON report written by the run
+	Progress *Progress     `json:"progress,omitempty"` // Live while running, final counts once finished
+
+	cancel context.CancelFunc
+}
+
+func (j *Job) finished() bool {
+	return j.Status != JobQueued && j.Status != JobRunning
+}
+
+// JobQueue runs jobs one at a time, in the order they were created, and persists them to Path
+// The scraper keeps its state in globals, so only one job can run per process
+type JobQueue struct {
+	Path     string
+	Defaults ScrapeOptions
+	mutex    sync.Mutex
+	jobs     []*Job
+	wake     chan struct{}
+}
+
+// OpenJobQueue loads the jobs persisted at path
+// Jobs that were running when the server stopped are queued again from the start
+func OpenJobQueue(path string, defaults ScrapeOptions) (*JobQueue, error) {
+	queue := &JobQueue{Path: path, Defaults: defaults, wake: make(chan struct{}, 1)}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return queue, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &queue.jobs); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	for _, job := range queue.jobs {
+		if job.Status == JobRunning {
+			slog.Warn("Requeuing job interrupted by a restart", "job", job.ID, "username", job.Username)
+			job.Status, job.Started, job.Progress = JobQueued, nil, nil
+		}
+	}
+	queue.save()
+	return queue, nil
+}
+
+// save writes the jobs to disk, the caller must hold the mutex
+func (q *JobQueue) save() {
+	data, err := json.MarshalIndent(q.jobs, "", "  ")
+	if err != nil {
+		slog.Error("Error encoding jobs", "error", err)
+		return
+	}
+	if _, err := WriteFileAtomic(bytes.NewReader(data), q.Path, int64(len(data))); err != nil {
+		slog.Error("Error writing jobs file", "path", q.Path, "error", err)
+	}
+}
+
+// Add queues a scrape of username, returning a copy of the new job
+func (q *JobQueue) Add(username string, options ScrapeOptions) Job {
+	id := make([]byte, 8)
+	rand.Read(id)
+	job := &Job{ID: fmt.Sprintf("%x", id), Username: username, Options: options, Status: JobQueued, Created: time.Now()}
+
+	q.mutex.Lock()
+	q.jobs = append(q.jobs, job)
+	q.save()
+	created := *job
+	q.mutex.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return created
+}
+
+// Get returns a copy of a job, with live progress while it runs
+func (q *JobQueue) Get(id string) (Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, job := range q.jobs {
+		if job.ID == id {
+			return q.snapshot(job), nil
+		}
+	}
+	return Job{}, ErrJobNotFound
+}
+
+// List returns copies of every job, oldest first
+func (q *JobQueue) List() []Job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, q.snapshot(job))
+	}
+	return jobs
+}
+
+func (q *JobQueue) snapshot(job *Job) Job {
+	copied := *job
+	if job.Status == JobRunning {
+		progress := TakeProgress()
+		copied.Progress = &progress
+	}
+	return copied
+}
+
+// Cancel drops a queued job, or stops a running one once its in-flight requests return
+func (q *JobQueue) Cancel(id string) (Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, job := range q.jobs {
+		if job.ID != id {
+			continue
+		}
+		switch job.Status {
+		case JobQueued:
+			now := time.Now()
+			job.Status, job.Finished = JobCancelled, &now
+			q.save()
+		case JobRunning:
+			job.cancel()
+		default:
+			return *job, ErrJobFinished
+		}
+		return q.snapshot(job), nil
+	}
+	return Job{}, ErrJobNotFound
+}
+
+// Run executes queued jobs until ctx is cancelled
+func (q *JobQueue) Run(ctx context.Context) {
+	for {
+		if job, jobContext := q.next(ctx); job != nil {
+			q.execute(job, jobContext)
+			continue
+		}
+
+		select {
+		case <-q.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// next marks the oldest queued job as running
+func (q *JobQueue) next(ctx context.Context) (*Job, context.Context) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, job := range q.jobs {
+		if job.Status == JobQueued {
+			now := time.Now()
+			jobContext, cancel := context.WithCancel(ctx)
+			job.Status, job.Started, job.cancel = JobRunning, &now, cancel
+			q.save()
+			return job, jobContext
+		}
+	}
+	return nil, nil
+}
+
+func (q *JobQueue) execute(job *Job, jobContext context.Context) {
+	logger := slog.Default()
+	slog.SetDefault(logger.With("job", job.ID))
+	defer slog.SetDefault(logger)
+
+	slog.Info("Starting job", "username", job.Username)
+	ResetRunState()
+	RunContext = jobContext
+	job.Options.apply()
+	inputUsername(job.Username)
+	err := runJob()
+
+	progress := TakeProgress()
+	ReportMutex.Lock()
+	report := LastJSONReport
+	ReportMutex.Unlock()
+
+	q.mutex.Lock()
+	now := time.Now()
+	job.Finished, job.Progress, job.Report = &now, &progress, report
+	switch {
+	case jobContext.Err() != nil:
+		job.Status = JobCancelled
+	case err != nil:
+		job.Status, job.Error = JobFailed, err.Error()
+	default:
+		job.Status = JobCompleted
+	}
+	job.cancel()
+	q.save()
+	q.mutex.Unlock()
+
+	slog.Info("Finished job", "username", job.Username, "status", job.Status, "duration", now.Sub(*job.Started))
+}
+
+// runJob runs the scrape, turning a panic or a Fatal error into the job's error rather than taking the server down
+func runJob() (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		StopDashboard()
+		CloseRunWARC()
+		FinishCatalogRun()
+		if fatal, ok := recovered.(FatalError); ok {
+			// Fatal has already fired run.error
+			err = fatal
+			return
+		}
+		err = fmt.Errorf("scrape failed: %v", recovered)
+		FireHook(HookRunError, map[string]any{"message": err.Error()})
+		FlushHooks()
+	}()
+
+	Scrape()
+	if TimemapFailed && !Cancelled() {
+		return fmt.Errorf("error fetching the list of Wayback Machine cached pages")
+	}
+	if TotalPages == 0 && !Cancelled() {
+		return fmt.Errorf("found no cached Wayback Machine pages")
+	}
+	return nil
+}
+
+// Handler serves the REST API
+func (q *JobQueue) Handler() nethttp.Handler {
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("POST /jobs", q.createJob)
+	mux.HandleFunc("GET /jobs", q.listJobs)
+	mux.HandleFunc("GET /jobs/{id}", q.getJob)
+	mux.HandleFunc("POST /jobs/{id}/cancel", q.cancelJob)
+	mux.HandleFunc("DELETE /jobs/{id}", q.cancelJob)
+	mux.HandleFunc("GET /jobs/{id}/report", q.jobReport)
+	mux.HandleFunc("GET /jobs/{id}/results.zip", q.jobResults)
+	return mux
+}
+
+// JobRequest is the body of POST /jobs, options left out keep the server's defaults
+type JobRequest struct {
+	Username string        `json:"username"`
+	Options  ScrapeOptions `json:"options"`
+}
+
+func (q *JobQueue) createJob(w nethttp.ResponseWriter, r *nethttp.Request) {
+	request := JobRequest{Options: q.Defaults}
+	if err := json.NewDecoder(nethttp.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+		writeError(w, nethttp.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	username, err := CleanUsername(request.Username)
+	if err != nil {
+		writeError(w, nethttp.StatusBadRequest, err)
+		return
+	}
+	if err := checkTransport(request.Options.Transport, request.Options.Profile); err != nil {
+		writeError(w, nethttp.StatusBadRequest, err)
+		return
+	}
+
+	job := q.Add(username, request.Options)
+	slog.Info("Queued job", "job", job.ID, "username", username)
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, nethttp.StatusCreated, job)
+}
+
+func (q *JobQueue) listJobs(w nethttp.ResponseWriter, r *nethttp.Request) {
+	writeJSON(w, nethttp.StatusOK, q.List())
+}
+
+func (q *JobQueue) getJob(w nethttp.ResponseWriter, r *nethttp.Request) {
+	job, err := q.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, nethttp.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, job)
+}
+
+func (q *JobQueue) cancelJob(w nethttp.ResponseWriter, r *nethttp.Request) {
+	job, err := q.Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		writeError(w, nethttp.StatusNotFound, err)
+	case errors.Is(err, ErrJobFinished):
+		writeError(w, nethttp.StatusConflict, err)
+	case job.Status == JobRunning:
+		// Stopping takes until the in-flight requests return, poll the job for the cancelled status
+		writeJSON(w, nethttp.StatusAccepted, job)
+	default:
+		writeJSON(w, nethttp.StatusOK, job)
+	}
+}
+
+// finishedJob looks up a job for the download endpoints, writing the error response if it has nothing to download yet
+func (q *JobQueue) finishedJob(w nethttp.ResponseWriter, r *nethttp.Request) (Job, bool) {
+	job, err := q.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, nethttp.StatusNotFound, err)
+		return job, false
+	}
+	if !job.finished() {
+		writeError(w, nethttp.StatusConflict, fmt.Errorf("job is %s", job.Status))
+		return job, false
+	}
+	return job, true
+}
+
+func (q *JobQueue) jobReport(w nethttp.ResponseWriter, r *nethttp.Request) {
+	job, ok := q.finishedJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Report == "" {
+		writeError(w, nethttp.StatusNotFound, fmt.Errorf("job did not write a report"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	nethttp.ServeFile(w, r, job.Report)
+}
+
+func (q *JobQueue) jobResults(w nethttp.ResponseWriter, r *nethttp.Request) {
+	job, ok := q.finishedJob(w, r)
+	if !ok {
+		return
+	}
+	dir := filepath.Join(HomeDirectory, "images", job.Username)
+	if _, err := os.Stat(dir); err != nil {
+		writeError(w, nethttp.StatusNotFound, fmt.Errorf("no results for %s", job.Username))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.Username+".zip"))
+	if err := writeZip(w, dir, job.Username); err != nil {
+		// Headers are already sent, so the client sees a truncated archive
+		slog.Error("Error writing results archive", "job", job.ID, "path", dir, "error", err)
+	}
+}
+
+// writeZip streams every finished file under dir into a zip archive, nested under prefix
+func writeZip(writer io.Writer, dir string, prefix string) error {
+	archive := zip.NewWriter(writer)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || strings.HasSuffix(path, PartialSuffix) {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header.Name = prefix + "/" + filepath.ToSlash(relative)
+		header.Method = zip.Deflate
+
+		output, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(output, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeJSON(w nethttp.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Error("Error writing API response", "error", err)
+	}
+}
+
+func writeError(w nethttp.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// ServeJobs runs queued jobs in the background and serves the REST API on ServeAddr
+func ServeJobs(queue *JobQueue) error {
+	go queue.Run(context.Background())
+	slog.Info("Serving REST API", "url", "http://"+ServeAddr+"/jobs", "jobs", queue.Path)
+	return nethttp.ListenAndServe(ServeAddr, queue.Handler())
+}
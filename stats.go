@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// LatencySummary accumulates request durations for a stage
+type LatencySummary struct {
+	Requests       int     `json:"requests"`
+	TotalSeconds   float64 `json:"total_seconds"`
+	AverageSeconds float64 `json:"average_seconds"`
+}
+
+// RunStats is the per-year and per-resource breakdown of a run, derived from its RunReport
+type RunStats struct {
+	SnapshotsByYear      map[string]int            `json:"snapshots_by_year"`
+	SnapshotsByMonth     map[string]int            `json:"snapshots_by_month"`
+	ImagesByResource     map[string]map[string]int `json:"images_by_resource"` // resource -> status -> count
+	Outcomes             map[string]int            `json:"outcomes"`           // "stage status: reason" -> count
+	BytesDownloaded      int64                     `json:"bytes_downloaded"`
+	Latency              map[string]LatencySummary `json:"latency"`
+	DuplicatesRemoved    int                       `json:"duplicates_removed"`    // Repeat sightings of the same image across pages
+	PreviouslyDownloaded int                       `json:"previously_downloaded"` // Skipped because they were already on disk
+}
+
+// RecordLatency adds a request's duration to its stage's summary
+func RecordLatency(stage string, duration time.Duration) {
+	ReportMutex.Lock()
+	defer ReportMutex.Unlock()
+
+	summary := Latencies[stage]
+	summary.Requests += 1
+	summary.TotalSeconds += duration.Seconds()
+	summary.AverageSeconds = summary.TotalSeconds / float64(summary.Requests)
+	Latencies[stage] = summary
+
+	ObserveLatency(stage, duration)
+}
+
+// BuildStats derives the statistics from a run report so saved reports can be summarised later
+func BuildStats(report RunReport) RunStats {
+	stats := RunStats{
+		SnapshotsByYear:      make(map[string]int),
+		SnapshotsByMonth:     make(map[string]int),
+		ImagesByResource:     make(map[string]map[string]int),
+		Outcomes:             make(map[string]int),
+		Latency:              report.Latency,
+		PreviouslyDownloaded: report.Counts["images_filtered"],
+	}
+	if stats.Latency == nil {
+		stats.Latency = make(map[string]LatencySummary)
+	}
+
+	for _, page := range report.Pages {
+		captured := ParseWaybackTimestamp(page.Timestamp)
+		if captured.IsZero() {
+			continue
+		}
+		stats.SnapshotsByYear[captured.Format("2006")] += 1
+		stats.SnapshotsByMonth[captured.Format("2006-01")] += 1
+
+		outcome := "page " + page.Status
+		if page.Reason != "" {
+			outcome += ": " + page.Reason
+		}
+		stats.Outcomes[outcome] += 1
+	}
+
+	for _, image := range report.Images {
+		if stats.ImagesByResource[image.Resource] == nil {
+			stats.ImagesByResource[image.Resource] = make(map[string]int)
+		}
+		stats.ImagesByResource[image.Resource][image.Status] += 1
+		stats.BytesDownloaded += image.Size
+
+		outcome := "image " + image.Status
+		if image.Reason != "" {
+			outcome += ": " + image.Reason
+		}
+		stats.Outcomes[outcome] += 1
+	}
+
+	for _, failure := range report.Failures {
+		stats.Outcomes[failure.Stage+" failed: "+failure.Error] += 1
+	}
+
+	if report.Counts["image_sightings"] > len(report.Discovered) {
+		stats.DuplicatesRemoved = report.Counts["image_sightings"] - len(report.Discovered)
+	}
+
+	return stats
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PrintStats writes the statistics as aligned tables
+func PrintStats(stats RunStats) {
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "\nYEAR\tSNAPSHOTS")
+	for _, year := range sortedKeys(stats.SnapshotsByYear) {
+		fmt.Fprintf(table, "%s\t%d\n", year, stats.SnapshotsByYear[year])
+	}
+
+	fmt.Fprintln(table, "\nMONTH\tSNAPSHOTS")
+	for _, month := range sortedKeys(stats.SnapshotsByMonth) {
+		fmt.Fprintf(table, "%s\t%d\n", month, stats.SnapshotsByMonth[month])
+	}
+
+	fmt.Fprintln(table, "\nRESOURCE\tDOWNLOADED\tSKIPPED")
+	for _, resource := range sortedKeys(stats.ImagesByResource) {
+		fmt.Fprintf(table, "%s\t%d\t%d\n", resource, stats.ImagesByResource[resource]["downloaded"], stats.ImagesByResource[resource]["skipped"])
+	}
+
+	fmt.Fprintln(table, "\nOUTCOME\tCOUNT")
+	for _, outcome := range sortedKeys(stats.Outcomes) {
+		fmt.Fprintf(table, "%s\t%d\n", strings.ReplaceAll(outcome, "\t", " "), stats.Outcomes[outcome])
+	}
+
+	fmt.Fprintln(table, "\nSTAGE\tREQUESTS\tAVERAGE LATENCY")
+	for _, stage := range sortedKeys(stats.Latency) {
+		summary := stats.Latency[stage]
+		fmt.Fprintf(table, "%s\t%d\t%.0fms\n", stage, summary.Requests, summary.AverageSeconds*1000)
+	}
+
+	fmt.Fprintln(table)
+	fmt.Fprintf(table, "Bytes downloaded\t%d\n", stats.BytesDownloaded)
+	fmt.Fprintf(table, "Duplicate sightings removed\t%d\n", stats.DuplicatesRemoved)
+	fmt.Fprintf(table, "Previously downloaded, skipped\t%d\n", stats.PreviouslyDownloaded)
+	table.Flush()
+}
+
+// printRunStats prints the statistics for the run in progress, or logs them as one event when logging JSON
+func printRunStats() {
+	stats := BuildStats(BuildRunReport())
+	switch {
+	case LogFormat == "json":
+		slog.Info("Run statistics", "stats", stats)
+	case !QuietLogs:
+		PrintStats(stats)
+	}
+}
+
+// printLatestStats prints the statistics from the user's most recent JSON report
+func printLatestStats(reportPath string) {
+	if reportPath == "" {
+		paths := RunReportPaths()
+		if len(paths) == 0 {
+			slog.Warn("No JSON reports found - run a scrape first", "path", UsernameLocation)
+			return
+		}
+		reportPath = paths[len(paths)-1]
+	}
+
+	report, err := LoadRunReport(reportPath)
+	if err != nil {
+		slog.Error("Error loading report", "path", reportPath, "error", err)
+		return
+	}
+
+	slog.Info("Statistics from report", "path", reportPath)
+	PrintStats(BuildStats(report))
+}
@@ -2,44 +2,89 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
-
-	"github.com/gookit/color"
+	"strings"
 )
 
 func CreateDirectories() {
 	UsernameLocation = filepath.Join(HomeDirectory, "images", TwitterUsername) // ./wayback-twitter-scraper/images/0xf6i
 	MediaDir = filepath.Join(UsernameLocation, "media")                        // ./wayback-twitter-scraper/images/0xf6i/media
 	ProfileDir = filepath.Join(UsernameLocation, "profile")                    // ./wayback-twitter-scraper/images/0xf6i/profile
+	BannerDir = filepath.Join(UsernameLocation, "banner")                      // ./wayback-twitter-scraper/images/0xf6i/banner
+	CorruptDir = filepath.Join(UsernameLocation, "corrupt")                    // ./wayback-twitter-scraper/images/0xf6i/corrupt
+	ThumbsDir = filepath.Join(UsernameLocation, "thumbs")                      // ./wayback-twitter-scraper/images/0xf6i/thumbs
+	CacheDir = filepath.Join(UsernameLocation, "cache")                        // ./wayback-twitter-scraper/images/0xf6i/cache
 
 	if err := os.MkdirAll(UsernameLocation, os.ModePerm); err != nil {
-		color.Red.Printf("Unable to create necessary directory %s: %s\n", UsernameLocation, err)
-		os.Exit(1)
+		Fatal("Unable to create necessary directory", "path", UsernameLocation, "error", err)
 	}
 	if err := os.MkdirAll(MediaDir, os.ModePerm); err != nil {
-		color.Red.Printf("Unable to create necessary directory %s: %s\n", MediaDir, err)
-		os.Exit(1)
+		Fatal("Unable to create necessary directory", "path", MediaDir, "error", err)
 	}
 	if err := os.MkdirAll(ProfileDir, os.ModePerm); err != nil {
-		color.Red.Printf("Unable to create necessary directory %s: %s\n", ProfileDir, err)
-		os.Exit(1)
+		Fatal("Unable to create necessary directory", "path", ProfileDir, "error", err)
+	}
+	if err := os.MkdirAll(BannerDir, os.ModePerm); err != nil {
+		Fatal("Unable to create necessary directory", "path", BannerDir, "error", err)
 	}
 }
 
 func CreateStoredImageMap() {
-	for _, directoryPath := range []string{MediaDir, ProfileDir} {
+	for _, directoryPath := range []string{MediaDir, ProfileDir, BannerDir} {
 		paths, err := filepath.Glob(fmt.Sprintf("%s/*", directoryPath))
 		if err != nil {
-			fmt.Println("Error:", err)
+			slog.Error("Error listing stored files", "path", directoryPath, "error", err)
 			return
 		}
 
 		for _, path := range paths {
+			// Partial files are left behind by interrupted downloads and are never complete
+			if strings.HasSuffix(path, PartialSuffix) {
+				os.Remove(path)
+				continue
+			}
 			StoredImageMap[filepath.Base(path)] = true
 		}
 	}
 	if len(StoredImageMap) > 0 {
-		color.HiMagenta.Printf("Discovered %d locally stored files - express filtering enabled\n", len(StoredImageMap))
+		slog.Info("Discovered locally stored files - express filtering enabled", "files", len(StoredImageMap))
+	}
+}
+
+// WriteFileAtomic streams reader into a temporary file beside path, syncs it and renames it into place
+// A crash mid-download therefore never leaves a truncated file under the final name
+// expectedSize is checked when non-negative, i.e. from the response Content-Length
+func WriteFileAtomic(reader io.Reader, path string, expectedSize int64) (int64, error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*"+PartialSuffix)
+	if err != nil {
+		return 0, err
+	}
+	tempPath := tempFile.Name()
+
+	written, err := io.Copy(tempFile, reader)
+	if err == nil && expectedSize >= 0 && written != expectedSize {
+		err = fmt.Errorf("wrote %d bytes, expected %d", written, expectedSize)
+	}
+	if err == nil {
+		// CreateTemp makes files private, downloads should have the usual permissions
+		err = tempFile.Chmod(0644)
+	}
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+
+	if err != nil {
+		os.Remove(tempPath)
+		return written, err
 	}
+	return written, nil
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// ThumbnailPath returns where the thumbnail for a downloaded file is stored, i.e. thumbs/media/<name>.jpg
+func ThumbnailPath(path string) string {
+	resource := filepath.Base(filepath.Dir(path))
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".jpg"
+	return filepath.Join(ThumbsDir, resource, name)
+}
+
+// thumbnailCurrent reports whether a thumbnail exists and is at least as new as its source
+func thumbnailCurrent(path string, thumbPath string) bool {
+	thumbInfo, err := os.Stat(thumbPath)
+	if err != nil {
+		return false
+	}
+	sourceInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return !thumbInfo.ModTime().Before(sourceInfo.ModTime())
+}
+
+// CreateThumbnail scales and centre-crops an image to a ThumbnailSize square JPEG
+func CreateThumbnail(path string, thumbPath string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	source, _, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+
+	// Crop the largest centred square so every thumbnail has the same dimensions
+	bounds := source.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	if side == 0 {
+		return fmt.Errorf("image has no pixels")
+	}
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, ThumbnailSize, ThumbnailSize))
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), source, crop, draw.Src, nil)
+
+	if err := os.MkdirAll(filepath.Dir(thumbPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, thumbnail, &jpeg.Options{Quality: 80}); err != nil {
+		return err
+	}
+	_, err = WriteFileAtomic(&encoded, thumbPath, int64(encoded.Len()))
+	return err
+}
+
+// createThumbnails generates thumbnails for every decodable image in the resource directories
+func createThumbnails() {
+	slog.Info("=== Generating thumbnails", "stage", "thumbnails", "path", ThumbsDir)
+
+	var paths []string
+	for _, resource := range Resources {
+		resourcePaths, err := filepath.Glob(filepath.Join(UsernameLocation, resource, "*"))
+		if err != nil {
+			slog.Error("Error listing files", "stage", "thumbnails", "resource", resource, "error", err)
+			continue
+		}
+		paths = append(paths, resourcePaths...)
+	}
+
+	var wg sync.WaitGroup
+	var counterMutex sync.Mutex
+	sem := make(chan struct{}, ThumbnailThreads) // Decoding is CPU bound so this pool is separate from MaxThreads
+
+	created, skipped, failed := 0, 0, 0
+	for _, path := range paths {
+		if strings.HasSuffix(path, PartialSuffix) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+
+			sem <- struct{}{}        // Acquire semaphore
+			defer func() { <-sem }() // Release semaphore
+
+			thumbPath := ThumbnailPath(path)
+			if thumbnailCurrent(path, thumbPath) {
+				counterMutex.Lock()
+				skipped += 1
+				counterMutex.Unlock()
+				return
+			}
+
+			err := CreateThumbnail(path, thumbPath)
+
+			counterMutex.Lock()
+			defer counterMutex.Unlock()
+			if err != nil {
+				// Videos and corrupted files cannot be decoded, they simply have no thumbnail
+				failed += 1
+				slog.Debug("No thumbnail", "stage", "thumbnails", "path", path, "reason", err)
+				return
+			}
+			created += 1
+		}(path)
+	}
+	wg.Wait()
+
+	slog.Info("Thumbnails generated", "stage", "thumbnails", "created", created, "present", skipped, "undecodable", failed)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "media", "GOOD.jpg")
+	os.MkdirAll(filepath.Dir(source), os.ModePerm)
+	if err := os.WriteFile(source, fakeJPEG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	thumbPath := filepath.Join(dir, "thumbs", "media", "GOOD.jpg")
+	if err := CreateThumbnail(source, thumbPath); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(thumbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil || config.Width != ThumbnailSize || config.Height != ThumbnailSize {
+		t.Errorf("thumbnail is %dx%d (%v), want %dx%[4]d", config.Width, config.Height, err, ThumbnailSize)
+	}
+	// Thumbnails are written through a private temporary file, but should end up readable like any other file
+	if info, err := os.Stat(thumbPath); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0644 {
+		t.Errorf("thumbnail mode = %v, want 0644", info.Mode().Perm())
+	}
+}
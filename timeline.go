@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// TimelineEntry is a single avatar or banner and the period it was visible in the archive
+type TimelineEntry struct {
+	Resource  string    `json:"resource"`
+	URL       string    `json:"url"`
+	File      string    `json:"file"` // Path relative to the user directory, empty if never downloaded
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Sightings int       `json:"sightings"`
+}
+
+var timelineTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Username}} - Avatar and Banner Timeline</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.5em; text-align: left; }
+img { max-height: 120px; }
+</style>
+</head>
+<body>
+<h1>{{.Username}} - Avatar and Banner Timeline</h1>
+<table>
+<tr><th>Type</th><th>Image</th><th>First Seen</th><th>Last Seen</th><th>Sightings</th></tr>
+{{range .Entries}}<tr>
+<td>{{.Resource}}</td>
+<td>{{if .File}}<img src="{{.File}}" alt="{{.URL}}">{{else}}<a href="{{.URL}}">{{.URL}}</a>{{end}}</td>
+<td>{{.FirstSeen.Format "2006-01-02 15:04"}}</td>
+<td>{{.LastSeen.Format "2006-01-02 15:04"}}</td>
+<td>{{.Sightings}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// BuildTimeline orders every recorded avatar and banner by when it was first seen
+func BuildTimeline() []TimelineEntry {
+	ProvenanceMutex.Lock()
+	defer ProvenanceMutex.Unlock()
+
+	entries := []TimelineEntry{}
+	for imageURL, provenance := range ImageProvenance {
+		resource := ImageResource(imageURL)
+		if resource != "profile" && resource != "banner" {
+			continue
+		}
+
+		file := filepath.Join(resource, ImageFilename(imageURL))
+		if _, err := os.Stat(filepath.Join(UsernameLocation, file)); err != nil {
+			file = ""
+		}
+
+		entries = append(entries, TimelineEntry{
+			Resource:  resource,
+			URL:       imageURL,
+			File:      filepath.ToSlash(file),
+			FirstSeen: ParseWaybackTimestamp(provenance.FirstSeen),
+			LastSeen:  ParseWaybackTimestamp(provenance.LastSeen),
+			Sightings: provenance.Sightings,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].FirstSeen.Equal(entries[j].FirstSeen) {
+			return entries[i].URL < entries[j].URL
+		}
+		return entries[i].FirstSeen.Before(entries[j].FirstSeen)
+	})
+
+	return entries
+}
+
+// createTimeline writes timeline.json and timeline.html to the user directory
+func createTimeline() {
+	entries := BuildTimeline()
+	if len(entries) == 0 {
+		slog.Warn("No avatar or banner sightings recorded - run a scrape first", "username", TwitterUsername)
+		return
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		slog.Error("Error encoding timeline", "error", err)
+		return
+	}
+
+	jsonPath := filepath.Join(UsernameLocation, "timeline.json")
+	if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+		slog.Error("Error writing timeline file", "error", err)
+		return
+	}
+
+	htmlPath := filepath.Join(UsernameLocation, "timeline.html")
+	htmlFile, err := os.Create(htmlPath)
+	if err != nil {
+		slog.Error("Error creating timeline page", "error", err)
+		return
+	}
+	defer htmlFile.Close()
+
+	err = timelineTemplate.Execute(htmlFile, map[string]interface{}{
+		"Username": TwitterUsername,
+		"Entries":  entries,
+	})
+	if err != nil {
+		slog.Error("Error writing timeline page", "error", err)
+		return
+	}
+
+	slog.Info("Timeline created", "entries", len(entries), "path", htmlPath)
+}
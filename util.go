@@ -2,16 +2,19 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/common-nighthawk/go-figure"
-	"github.com/gookit/color"
 )
 
 func DrawTitle() {
+	// The figure is coloured, so it is drawn only for people reading a terminal that allows colour
+	if LogFormat != "text" || QuietLogs || !useColor(os.Stdout) {
+		return
+	}
 	myFigure := figure.NewColorFigure("WaybackScraper", "poison", "blue", true)
 	myFigure.Print()
 }
@@ -19,32 +22,43 @@ func DrawTitle() {
 func GetPWD() string {
 	pwd, err := os.Getwd()
 	if err != nil {
-		color.Red.Println("Error getting PWD:", err)
+		slog.Error("Error getting PWD", "error", err)
 		return ""
 	}
 	return pwd
 }
 
 func InvalidUsernameCheck() bool {
-	// if TwitterUsername contains the twitter URL remove it
-	if strings.Contains(TwitterUsername, "twitter.com/") {
-		TwitterUsername = strings.Split(TwitterUsername, ".com/")[1]
-	}
-
-	if TwitterUsername == "" {
-		fmt.Println(`"" - is not a valid username`)
+	username, err := CleanUsername(TwitterUsername)
+	TwitterUsername = username
+	switch err {
+	case ErrEmptyUsername:
+		slog.Warn(`"" - is not a valid username`)
 		return true
-	}
-
-	valid := regexp.MustCompile(`^[a-zA-Z0-9_]{1,15}$`).MatchString(TwitterUsername)
-	if !valid {
-		fmt.Println("Username can only contain alphanumeric characters and underscores (1-15 characters)")
+	case ErrInvalidUsername:
+		slog.Warn("Username can only contain alphanumeric characters and underscores (1-15 characters)", "username", TwitterUsername)
 		return true
 	}
 
 	return false
 }
 
+// CleanUsername strips a twitter.com URL down to its username and checks what is left is a valid handle
+func CleanUsername(username string) (string, error) {
+	// if username contains the twitter URL remove it
+	if strings.Contains(username, "twitter.com/") {
+		username = strings.Split(username, ".com/")[1]
+	}
+
+	if username == "" {
+		return username, ErrEmptyUsername
+	}
+	if !UsernamePattern.MatchString(username) {
+		return username, ErrInvalidUsername
+	}
+	return username, nil
+}
+
 func GetCurrentDate() string {
 	currentTime := time.Now()
 	dateString := currentTime.Format("2006-01-02")
@@ -52,22 +66,49 @@ func GetCurrentDate() string {
 }
 
 func GetPageProgress() string {
+	PageMutex.Lock()
+	defer PageMutex.Unlock()
 	return fmt.Sprintf("[%d / %d]", len(PageProcessed), TotalPages)
 }
 
 func GetImageProgress() string {
+	ImageMutex.Lock()
+	defer ImageMutex.Unlock()
 	return fmt.Sprintf("[%d / %d]", len(ImageProcessed), TotalImages)
 }
 
-func Pop(slice []string) ([]string, string) {
+func Pop[T any](slice []T) ([]T, T) {
 	if len(slice) == 0 {
-		return slice, ""
+		var zero T
+		return slice, zero
 	}
 	popped := slice[len(slice)-1]
 	slice = slice[:len(slice)-1]
 	return slice, popped
 }
 
+// Returns the resource type ("media", "profile" or "banner") an image URL belongs to
+func ImageResource(imageURL string) string {
+	switch {
+	case strings.Contains(imageURL, "profile_banners"):
+		return "banner"
+	case strings.Contains(imageURL, "media"):
+		return "media"
+	default:
+		return "profile"
+	}
+}
+
+// Returns the local filename for an image URL
+// Banners have no extension, so their user ID, upload timestamp and size are joined instead
+func ImageFilename(imageURL string) string {
+	if ImageResource(imageURL) == "banner" {
+		parts := strings.Split(strings.TrimPrefix(imageURL, "https://pbs.twimg.com/profile_banners/"), "/")
+		return strings.Join(parts, "_") + ".jpg"
+	}
+	return FilenameRegex.FindString(imageURL)
+}
+
 // Removes duplicate items from a slice
 func RemoveDuplicates(inputSlice []string) []string {
 	uniqueSlice := make([]string, 0)
@@ -86,22 +127,25 @@ func RemoveDuplicates(inputSlice []string) []string {
 
 // Removes the objects stored in StoredImageMap from the unprocessedImageSlice
 func RemoveCommonItems() {
+	ImageMutex.Lock()
+	defer ImageMutex.Unlock()
+
 	tempSlice := []string{}
 
 	for _, item := range ImageUnprocessed {
 		// regex item for just filename
-		itemFilename := FilenameRegex.FindString(item)
+		itemFilename := ImageFilename(item)
 		if !StoredImageMap[itemFilename] {
 			tempSlice = append(tempSlice, item)
 		}
 	}
-	color.Magenta.Printf("Filtered %d previously downloaded images - %s\n", len(ImageUnprocessed)-len(tempSlice), UsernameLocation)
+	FilteredImages = len(ImageUnprocessed) - len(tempSlice)
+	slog.Info("Filtered previously downloaded images", "images", FilteredImages, "path", UsernameLocation)
 	ImageUnprocessed = tempSlice
 }
 
 func CheckIfProxiesExist() bool {
-	proxyFilePath := fmt.Sprintf("%s/proxies/proxies.txt", HomeDirectory)
-	if _, err := os.Stat(proxyFilePath); err == nil {
+	if _, err := os.Stat(ProxyFile); err == nil {
 		return true
 	}
 	UseProxies = false
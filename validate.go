@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Signature pairs a file format with the magic bytes found at the given offset
+type Signature struct {
+	Format string
+	Offset int
+	Magic  []byte
+}
+
+var (
+	// Formats that are decoded with the image package when DecodeImages is enabled
+	decodableFormats = map[string]bool{"jpeg": true, "png": true, "gif": true, "webp": true}
+
+	signatures = []Signature{
+		{Format: "jpeg", Offset: 0, Magic: []byte{0xFF, 0xD8, 0xFF}},
+		{Format: "png", Offset: 0, Magic: []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}},
+		{Format: "gif", Offset: 0, Magic: []byte("GIF87a")},
+		{Format: "gif", Offset: 0, Magic: []byte("GIF89a")},
+		{Format: "webp", Offset: 8, Magic: []byte("WEBP")}, // Preceded by "RIFF" and the chunk size
+		{Format: "mp4", Offset: 4, Magic: []byte("ftyp")},
+	}
+)
+
+// DetectFormat returns the format matching the leading bytes of a file, or "" if none match
+func DetectFormat(header []byte) string {
+	for _, signature := range signatures {
+		end := signature.Offset + len(signature.Magic)
+		if len(header) < end || !bytes.Equal(header[signature.Offset:end], signature.Magic) {
+			continue
+		}
+		if signature.Format == "webp" && !bytes.Equal(header[:4], []byte("RIFF")) {
+			continue
+		}
+		return signature.Format
+	}
+	return ""
+}
+
+// ValidateFile checks a file's signature and, if DecodeImages is set, fully decodes images to catch truncation
+func ValidateFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, 16)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("unreadable header: %w", err)
+	}
+
+	format := DetectFormat(header[:n])
+	if format == "" {
+		return fmt.Errorf("unrecognised file signature")
+	}
+
+	if !DecodeImages || !decodableFormats[format] {
+		return nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, _, err := image.Decode(file); err != nil {
+		return fmt.Errorf("%s failed to decode: %w", format, err)
+	}
+	return nil
+}
+
+// quarantine moves a bad file into corrupt/<resource>/ so it can be inspected rather than lost
+func quarantine(path string) (string, error) {
+	resource := filepath.Base(filepath.Dir(path))
+	quarantineDir := filepath.Join(CorruptDir, resource)
+	if err := os.MkdirAll(quarantineDir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	destination := filepath.Join(quarantineDir, filepath.Base(path))
+	return destination, os.Rename(path, destination)
+}
+
+// purgeCorrupted validates every file in the resource directories and quarantines the invalid ones
+func purgeCorrupted() {
+	slog.Info("Validating downloaded files", "stage", "validate", "path", UsernameLocation)
+
+	checkedCounter := 0
+	quarantinedCounter := 0
+
+	for _, directoryPath := range []string{MediaDir, ProfileDir, BannerDir} {
+		paths, err := filepath.Glob(fmt.Sprintf("%s/*", directoryPath))
+		if err != nil {
+			slog.Error("Error listing files", "stage", "validate", "path", directoryPath, "error", err)
+			continue
+		}
+
+		for _, path := range paths {
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				continue
+			}
+			checkedCounter += 1
+
+			validationErr := ValidateFile(path)
+			if validationErr == nil {
+				continue
+			}
+
+			destination, err := quarantine(path)
+			if err != nil {
+				slog.Error("Error quarantining corrupted file", "stage", "validate", "path", path, "error", err)
+				continue
+			}
+			slog.Warn("Quarantined corrupted file", "stage", "validate", "path", path, "reason", validationErr, "destination", destination)
+			FireHook(HookImageQuarantined, map[string]any{"path": path, "destination": destination, "reason": validationErr.Error()})
+			quarantinedCounter += 1
+		}
+	}
+
+	QuarantinedFiles += quarantinedCounter
+
+	if quarantinedCounter == 0 {
+		slog.Info("Validated files - no corrupted files found", "stage", "validate", "files", checkedCounter)
+	} else {
+		slog.Info("Validated files - quarantined corrupted files", "stage", "validate", "files", checkedCounter, "quarantined", quarantinedCounter, "path", CorruptDir)
+	}
+}
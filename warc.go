@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// WARCWriter appends gzip-per-record WARC/1.1 records to a single file
+type WARCWriter struct {
+	mutex sync.Mutex
+	file  *os.File
+	Path  string
+}
+
+func newRecordID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = (id[6] & 0x0f) | 0x40 // Version 4
+	id[8] = (id[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+// OpenWARC creates the WARC file and writes the warcinfo record describing the run
+func OpenWARC(path string) (*WARCWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	writer := &WARCWriter{file: file, Path: path}
+
+	info := fmt.Sprintf("software: wayback-twitter-scraper\r\nformat: WARC File Format 1.1\r\nusername: %s\r\nstart-time: %s\r\nmax-threads: %d\r\nretry-attempts: %d\r\n",
+		TwitterUsername, RunStarted.UTC().Format(time.RFC3339), MaxThreads, RetryAttempts)
+	headers := [][2]string{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Filename", filepath.Base(path)},
+		{"Content-Type", "application/warc-fields"},
+	}
+	if err := writer.WriteRecord(headers, strings.NewReader(info), int64(len(info))); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// WriteRecord writes a single record as its own gzip member so readers can seek between records
+func (w *WARCWriter) WriteRecord(headers [][2]string, block io.Reader, length int64) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	compressor := gzip.NewWriter(w.file)
+
+	fmt.Fprint(compressor, "WARC/1.1\r\n")
+	fmt.Fprintf(compressor, "WARC-Date: %s\r\n", time.Now().UTC().Format(time.RFC3339))
+	for _, header := range headers {
+		fmt.Fprintf(compressor, "%s: %s\r\n", header[0], header[1])
+	}
+	fmt.Fprintf(compressor, "Content-Length: %d\r\n\r\n", length)
+
+	if _, err := io.Copy(compressor, block); err != nil {
+		compressor.Close()
+		return err
+	}
+	fmt.Fprint(compressor, "\r\n\r\n")
+
+	return compressor.Close()
+}
+
+// WriteExchange writes a request record and the matching response record for a fetch
+// body is the response payload already read from the connection, size its length
+func (w *WARCWriter) WriteExchange(req *http.Request, resp *http.Response, body io.Reader, size int64) error {
+	targetURI := req.URL.String()
+	if resp.Request != nil && resp.Request.URL != nil {
+		targetURI = resp.Request.URL.String() // Record the final URL after Wayback's redirects
+	}
+
+	var responseHead bytes.Buffer
+	fmt.Fprintf(&responseHead, "HTTP/1.1 %s\r\n", resp.Status)
+	header := resp.Header.Clone()
+	// The payload has already been de-chunked and decompressed, so describe it as stored
+	header.Del("Transfer-Encoding")
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", fmt.Sprint(size))
+	header.Write(&responseHead)
+	responseHead.WriteString("\r\n")
+
+	responseID := newRecordID()
+	responseHeaders := [][2]string{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Target-URI", targetURI},
+		{"Content-Type", "application/http;msgtype=response"},
+	}
+	block := io.MultiReader(&responseHead, body)
+	if err := w.WriteRecord(responseHeaders, block, int64(responseHead.Len())+size); err != nil {
+		return err
+	}
+
+	var requestBlock bytes.Buffer
+	fmt.Fprintf(&requestBlock, "%s %s HTTP/1.1\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), req.URL.Host)
+	for _, key := range sortedKeys(req.Header) {
+		if key == http.HeaderOrderKey || key == http.PHeaderOrderKey {
+			continue
+		}
+		for _, value := range req.Header[key] {
+			fmt.Fprintf(&requestBlock, "%s: %s\r\n", key, value)
+		}
+	}
+	requestBlock.WriteString("\r\n")
+
+	requestRecordHeaders := [][2]string{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Target-URI", targetURI},
+		{"WARC-Concurrent-To", responseID},
+		{"Content-Type", "application/http;msgtype=request"},
+	}
+	return w.WriteRecord(requestRecordHeaders, &requestBlock, int64(requestBlock.Len()))
+}
+
+func (w *WARCWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.file.Close()
+}
+
+// ArchiveExchange records a fetch in the run's WARC file when WARC output is enabled
+func ArchiveExchange(req *http.Request, resp *http.Response, body io.Reader, size int64) {
+	if WARCOutput == nil {
+		return
+	}
+	if err := WARCOutput.WriteExchange(req, resp, body, size); err != nil {
+		slog.Error("Error writing WARC record", "url", req.URL.String(), "error", err)
+	}
+}
+
+// ArchiveResponse records a fetch whose payload was not kept, i.e. an error status or a rejected payload, reading the rest of body
+// These are small error and placeholder pages, so the body is read into memory
+func ArchiveResponse(req *http.Request, resp *http.Response, body io.Reader) {
+	if WARCOutput == nil {
+		return
+	}
+
+	payload, err := io.ReadAll(body)
+	if err != nil {
+		slog.Error("Error reading response for WARC output", "url", req.URL.String(), "status", resp.StatusCode, "error", err)
+		return
+	}
+	ArchiveExchange(req, resp, bytes.NewReader(payload), int64(len(payload)))
+}
+
+// ArchiveDownloadedFile records a downloaded file in the WARC, reading the payload back from disk
+func ArchiveDownloadedFile(req *http.Request, resp *http.Response, path string) {
+	if WARCOutput == nil {
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		slog.Error("Error opening downloaded file for WARC output", "path", path, "error", err)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		slog.Error("Error opening downloaded file for WARC output", "path", path, "error", err)
+		return
+	}
+	ArchiveExchange(req, resp, file, info.Size())
+}
+
+// OpenRunWARC starts the run's WARC file in the user directory when WriteWARC is set
+func OpenRunWARC() {
+	if !WriteWARC {
+		return
+	}
+
+	writer, err := OpenWARC(reportPath(".warc.gz"))
+	if err != nil {
+		slog.Error("Error creating WARC file", "error", err)
+		return
+	}
+	WARCOutput = writer
+	slog.Info("Writing WARC records", "path", writer.Path)
+}
+
+func CloseRunWARC() {
+	if WARCOutput == nil {
+		return
+	}
+	if err := WARCOutput.Close(); err != nil {
+		slog.Error("Error closing WARC file", "error", err)
+		return
+	}
+	slog.Info("WARC created", "path", WARCOutput.Path)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWARCRecordsEveryFetch(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+	previous := WriteWARC
+	t.Cleanup(func() { WriteWARC = previous })
+	WriteWARC = true
+
+	const (
+		missingPage = "https://twitter.com/jack/status/404"
+		placeholder = "https://pbs.twimg.com/media/HRM.jpg"
+		good        = "https://pbs.twimg.com/media/GOOD.jpg"
+	)
+	fake.AddTimemapEntry("20140101000000", missingPage)
+	fake.AddPage("20150101000000", "https://twitter.com/jack", `<html><body><img src="`+placeholder+`"><img src="`+good+`"></body></html>`)
+	fake.AddResponse(placeholder, &fakeResponse{Status: http.StatusOK, ContentType: "text/html", Body: []byte("<!DOCTYPE html><html><body><p>Hrm.</p></body></html>")})
+	fake.AddResponse(good, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: fakeJPEG(t), Archived: true})
+
+	inputUsername("jack")
+	CreateDirectories()
+	OpenRunWARC()
+	CreateStoredImageMap()
+	fetchWaybackPages()
+	parseImages()
+	downloadImages()
+	CloseRunWARC()
+
+	file, err := os.Open(WARCOutput.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warc := string(data)
+
+	// The 404 page and the rejected placeholder are archived alongside the saved image
+	for _, original := range []string{missingPage, placeholder, good} {
+		if !strings.Contains(warc, "if_/"+original+"\r\n") {
+			t.Errorf("WARC has no records for %s", original)
+		}
+	}
+	if !strings.Contains(warc, "HTTP/1.1 404 Not Found") || !strings.Contains(warc, "<p>Hrm.</p>") {
+		t.Error("WARC is missing the 404 response or the placeholder payload")
+	}
+	if !strings.Contains(warc, "user-agent: "+requestHeaders.Get("user-agent")) {
+		t.Error("WARC request records do not carry the headers sent")
+	}
+	// Two pages and two images
+	if count := strings.Count(warc, "WARC-Type: request"); count != 4 {
+		t.Errorf("WARC has %d request records, want 4", count)
+	}
+}
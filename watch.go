@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WatchState records how far an account has been checked, so the next check only processes newer captures
+type WatchState struct {
+	Username    string    `json:"username"`
+	LastSeen    string    `json:"last_seen"` // Newest capture timestamp processed along with every older one, i.e. 20200126021126
+	LastChecked time.Time `json:"last_checked"`
+	NewPages    int       `json:"new_pages"` // Captures processed by the latest check
+}
+
+func watchStatePath() string {
+	return filepath.Join(UsernameLocation, "watch.json")
+}
+
+// LoadWatchState reads the account's watch state, returning an empty one for an account never watched before
+func LoadWatchState() WatchState {
+	state := WatchState{Username: TwitterUsername}
+	data, err := os.ReadFile(watchStatePath())
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		slog.Error("Error decoding watch state", "path", watchStatePath(), "error", err)
+	}
+	return state
+}
+
+// SaveWatchState advances the account's last seen timestamp through the captures this run finished
+// It stops at the oldest capture whose page failed, or one of whose images failed, so the next check retries it
+func SaveWatchState(state WatchState) {
+	finished := map[Snapshot]bool{}
+	recorded := map[string]bool{}
+	ReportMutex.Lock()
+	state.NewPages = len(PageRecords)
+	for _, record := range PageRecords {
+		finished[Snapshot{Timestamp: record.Timestamp, URL: record.URL}] = true
+	}
+	for _, record := range ImageRecords {
+		recorded[record.URL] = true
+	}
+	discovered := DiscoveredImages
+	ReportMutex.Unlock()
+
+	// Images neither downloaded, skipped nor already stored leave every capture they were seen in unfinished
+	unfinished := map[string]bool{}
+	ProvenanceMutex.Lock()
+	for _, imageURL := range discovered {
+		if recorded[imageURL] || StoredImageMap[ImageFilename(imageURL)] {
+			continue
+		}
+		if provenance, ok := ImageProvenance[imageURL]; ok {
+			for _, timestamp := range provenance.Seen {
+				unfinished[timestamp] = true
+			}
+		}
+	}
+	ProvenanceMutex.Unlock()
+
+	snapshots := slices.Clone(PageSnapshots)
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Timestamp < snapshots[j].Timestamp })
+	for _, snapshot := range snapshots {
+		if !finished[snapshot] || unfinished[snapshot.Timestamp] {
+			slog.Info("Holding back watch progress for an unfinished capture", "username", state.Username, "timestamp", snapshot.Timestamp, "url", snapshot.URL)
+			break
+		}
+		if snapshot.Timestamp > state.LastSeen {
+			state.LastSeen = snapshot.Timestamp
+		}
+	}
+	state.LastChecked = time.Now()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		slog.Error("Error encoding watch state", "error", err)
+		return
+	}
+	if err := os.WriteFile(watchStatePath(), data, 0644); err != nil {
+		slog.Error("Error writing watch state", "path", watchStatePath(), "error", err)
+	}
+}
+
+// WatchAccounts checks each account once for captures newer than its last seen timestamp, stopping early if ctx is cancelled
+func WatchAccounts(ctx context.Context, usernames []string) {
+	for _, username := range usernames {
+		if ctx.Err() != nil {
+			return
+		}
+
+		ResetRunState()
+		RunContext = ctx
+		TwitterUsername = username
+		CreateDirectories()
+		state := LoadWatchState()
+		SinceTimestamp = state.LastSeen
+		slog.Info("Checking account for new captures", "username", username, "since", state.LastSeen)
+
+		inputUsername(username)
+		Scrape()
+
+		// A cancelled run may have skipped older pages, so it must not advance past them,
+		// and a failed timemap fetch did not check the account at all
+		if !Cancelled() && !TimemapFailed {
+			SaveWatchState(state)
+		}
+	}
+}
+
+// ReadWatchList reads usernames from a file, one per line, ignoring blank lines and # comments
+func ReadWatchList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var usernames []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		usernames = append(usernames, line)
+	}
+	return usernames, scanner.Err()
+}
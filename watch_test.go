@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestWatchProcessesOnlyNewCaptures(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+
+	const (
+		oldPage  = "https://twitter.com/jack"
+		newPage  = "https://twitter.com/jack/status/20"
+		oldMedia = "https://pbs.twimg.com/media/OLD.jpg"
+		newMedia = "https://pbs.twimg.com/media/NEW.jpg"
+	)
+	fake.AddPage("20150101000000", oldPage, `<html><body><img src="`+oldMedia+`"></body></html>`)
+	fake.AddResponse(oldMedia, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: fakeJPEG(t), Archived: true})
+
+	WatchAccounts(context.Background(), []string{"jack"})
+	if state := LoadWatchState(); state.LastSeen != "20150101000000" || state.NewPages != 1 {
+		t.Fatalf("watch state after first check = %+v, want last seen 20150101000000 with 1 new page", state)
+	}
+
+	// Nothing new, the next check should not revisit the old capture
+	WatchAccounts(context.Background(), []string{"jack"})
+	if hits := fake.Hits(oldPage); hits != 1 {
+		t.Errorf("old page requested %d times after an empty check, want 1", hits)
+	}
+
+	fake.AddPage("20160101000000", newPage, `<html><body><img src="`+newMedia+`"></body></html>`)
+	fake.AddResponse(newMedia, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: fakeJPEG(t), Archived: true})
+
+	WatchAccounts(context.Background(), []string{"jack"})
+	if hits := fake.Hits(oldPage); hits != 1 {
+		t.Errorf("old page requested %d times, want 1", hits)
+	}
+	if hits := fake.Hits(newPage); hits != 1 {
+		t.Errorf("new page requested %d times, want 1", hits)
+	}
+	if state := LoadWatchState(); state.LastSeen != "20160101000000" || state.NewPages != 1 {
+		t.Errorf("watch state after new capture = %+v, want last seen 20160101000000 with 1 new page", state)
+	}
+	if _, err := os.Stat(filepath.Join(MediaDir, "NEW.jpg")); err != nil {
+		t.Errorf("new image not saved: %v", err)
+	}
+
+	// The check only saw the new page, so the old page's image must not count as disappeared
+	report, err := LoadRunReport(LastJSONReport)
+	if err != nil || report.Since != "20150101000000" {
+		t.Fatalf("report since = %q (%v), want 20150101000000", report.Since, err)
+	}
+	previous := RunReport{Discovered: []string{oldMedia}}
+	if diff := DiffRuns(previous, report); len(diff.DisappearedImages) != 0 {
+		t.Errorf("incremental run reported %v as disappeared", diff.DisappearedImages)
+	}
+}
+
+func TestWatchRetriesFailedCaptures(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+
+	const (
+		oldPage  = "https://twitter.com/jack"
+		newPage  = "https://twitter.com/jack/status/20"
+		oldMedia = "https://pbs.twimg.com/media/OLD.jpg"
+	)
+	oldHTML := []byte(`<html><body><img src="` + oldMedia + `"></body></html>`)
+	fake.AddPage("20150101000000", oldPage, "")
+	fake.AddPage("20160101000000", newPage, `<html><body></body></html>`)
+	broken := &fakeResponse{Status: http.StatusInternalServerError, ContentType: "text/html"}
+
+	// The older capture fails while the newer one succeeds, so the next check must still go back for it
+	fake.AddResponse(oldPage, broken)
+	WatchAccounts(context.Background(), []string{"jack"})
+	if state := LoadWatchState(); state.LastSeen != "" {
+		t.Fatalf("last seen advanced to %s past the failed 2015 capture", state.LastSeen)
+	}
+
+	// Its page now loads but its image fails, which holds the account back just the same
+	fake.AddResponse(oldPage, &fakeResponse{Status: http.StatusOK, ContentType: "text/html; charset=utf-8", Body: oldHTML, Archived: true})
+	fake.AddResponse(oldMedia, broken)
+	hits := fake.Hits(oldPage)
+	WatchAccounts(context.Background(), []string{"jack"})
+	if fake.Hits(oldPage) == hits {
+		t.Error("the failed 2015 capture was not requested again")
+	}
+	if state := LoadWatchState(); state.LastSeen != "" {
+		t.Fatalf("last seen advanced to %s past the 2015 capture with a failed image", state.LastSeen)
+	}
+
+	fake.AddResponse(oldMedia, &fakeResponse{Status: http.StatusOK, ContentType: "image/jpeg", Body: fakeJPEG(t), Archived: true})
+	WatchAccounts(context.Background(), []string{"jack"})
+	if state := LoadWatchState(); state.LastSeen != "20160101000000" {
+		t.Errorf("last seen = %q once every capture succeeded, want 20160101000000", state.LastSeen)
+	}
+	if _, err := os.Stat(filepath.Join(MediaDir, "OLD.jpg")); err != nil {
+		t.Errorf("image from the retried capture not saved: %v", err)
+	}
+}
+
+func TestWatchTimemapFailure(t *testing.T) {
+	fake := newFakeWayback(t)
+	resetScrapeState(t, fake)
+	fake.AddPage("20150101000000", "https://twitter.com/jack", `<html><body></body></html>`)
+
+	WatchAccounts(context.Background(), []string{"jack"})
+	checked := LoadWatchState()
+
+	var mutex sync.Mutex
+	var events []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, r.Header.Get("X-Wayback-Scraper-Event"))
+	}))
+	defer webhook.Close()
+	useHooks(t, []string{webhook.URL}, nil, nil)
+
+	// Nothing listens on the fake's address once it is closed, so every timemap attempt fails
+	fake.Server.Close()
+	WatchAccounts(context.Background(), []string{"jack"})
+
+	if !TimemapFailed {
+		t.Error("a failed timemap fetch was not recorded")
+	}
+	if state := LoadWatchState(); !state.LastChecked.Equal(checked.LastChecked) {
+		t.Errorf("watch state advanced to %v after a failed check", state.LastChecked)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !slices.Contains(events, HookRunError) {
+		t.Errorf("webhook received %v, want run.error", events)
+	}
+}
+
+func TestReadWatchList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.txt")
+	if err := os.WriteFile(path, []byte("# accounts\njack\n\n  dorsey \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	usernames, err := ReadWatchList(path)
+	if err != nil || len(usernames) != 2 || usernames[0] != "jack" || usernames[1] != "dorsey" {
+		t.Errorf("ReadWatchList = %v, %v, want [jack dorsey]", usernames, err)
+	}
+}